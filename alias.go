@@ -0,0 +1,56 @@
+package apollo
+
+import "sync"
+
+var (
+	aliasLock        sync.RWMutex
+	namespaceAliases = make(map[string]string)
+)
+
+// RegisterNamespaceAlias 注册全局的命名空间别名，如 RegisterNamespaceAlias("global", "team.global.settings")，
+// 所有客户端实例的 Alias 都可以使用
+func RegisterNamespaceAlias(alias, namespace string) {
+	aliasLock.Lock()
+	defer aliasLock.Unlock()
+	namespaceAliases[alias] = normalizeNamespace(namespace)
+}
+
+// UnregisterNamespaceAlias 注销 RegisterNamespaceAlias 注册的全局别名
+func UnregisterNamespaceAlias(alias string) {
+	aliasLock.Lock()
+	defer aliasLock.Unlock()
+	delete(namespaceAliases, alias)
+}
+
+// Alias 返回别名对应命名空间的配置，先查 Options.NamespaceAliases 再查全局注册的别名，
+// 都没有时把 alias 当作命名空间名称
+func (config *Config) Alias(alias string) *NamespaceConfig {
+	return config.GetNamespace(config.resolveAlias(alias))
+}
+
+func (config *Config) resolveAlias(alias string) string {
+	if ns, ok := config.aliases[alias]; ok {
+		return ns
+	}
+	aliasLock.RLock()
+	defer aliasLock.RUnlock()
+	if ns, ok := namespaceAliases[alias]; ok {
+		return ns
+	}
+	return alias
+}
+
+// preloadAliases 启动时拉取 Options.NamespaceAliases 中别名对应的命名空间并加入通知，
+// 全局注册的别名在首次使用时才拉取
+func (config *Config) preloadAliases() {
+	seen := make(map[string]bool, len(config.aliases))
+	for _, ns := range config.aliases {
+		if seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		if _, ok := config.getCache(ns); !ok {
+			logger.Warnf("preload namespace %s fail", ns)
+		}
+	}
+}
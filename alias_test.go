@@ -0,0 +1,46 @@
+package apollo
+
+import "testing"
+
+func TestConfig_Alias(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_alias", "application", map[string]string{})
+	f.set("app_alias", "team.global.settings", map[string]string{"name": "global"})
+	f.set("app_alias", "svc.grpc", map[string]string{"name": "grpc"})
+
+	RegisterNamespaceAlias("alias_test_global", "Team.Global.Settings.properties")
+	t.Cleanup(func() { UnregisterNamespaceAlias("alias_test_global") })
+	c := f.newClient(t, Options{AppID: "app_alias",
+		NamespaceAliases: map[string]string{"grpc": "svc.grpc"}, PreloadAliases: true})
+
+	c.lock.RLock()
+	_, globalLoaded := c.nCache["team.global.settings"]
+	_, grpcLoaded := c.nCache["svc.grpc"]
+	c.lock.RUnlock()
+	// 只预加载本实例的别名，全局别名在首次使用时拉取
+	if globalLoaded || !grpcLoaded {
+		t.Errorf("preloaded global=%v grpc=%v, want only the options alias", globalLoaded, grpcLoaded)
+	}
+
+	if v := c.Alias("alias_test_global").GetString("name", ""); v != "global" {
+		t.Errorf("global alias got %q", v)
+	}
+	if v := c.Alias("grpc").GetString("name", ""); v != "grpc" {
+		t.Errorf("options alias got %q", v)
+	}
+	if ns := c.Alias("svc.grpc").Namespace; ns != "svc.grpc" {
+		t.Errorf("unknown alias resolved to %q, want the name itself", ns)
+	}
+}
+
+func TestUnregisterNamespaceAlias(t *testing.T) {
+	RegisterNamespaceAlias("alias_test_tmp", "team.tmp")
+	c := &Config{}
+	if ns := c.resolveAlias("alias_test_tmp"); ns != "team.tmp" {
+		t.Errorf("resolved to %q, want team.tmp", ns)
+	}
+	UnregisterNamespaceAlias("alias_test_tmp")
+	if ns := c.resolveAlias("alias_test_tmp"); ns != "alias_test_tmp" {
+		t.Errorf("resolved to %q after unregister, want the name itself", ns)
+	}
+}
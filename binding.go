@@ -0,0 +1,91 @@
+package apollo
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Binding 命名空间绑定的结构体，配置变更时重新解析并原子替换
+type Binding[T any] struct {
+	namespace  string
+	v          atomic.Value // *T，T 为接口类型时也能存入
+	reloadLock sync.Mutex   // 串行化解析，避免旧配置覆盖新配置
+	lock       sync.Mutex
+	onError    func(error)
+	remove     func()
+}
+
+// Bind 将命名空间按 apollo 标签解析为 T（须为结构体类型），之后每次该命名空间的配置变更都会
+// 重新解析出新的 T 并原子替换；解析失败时保留原值，并通过 OnError 注册的回调通知。
+// 首次解析失败时返回错误，不再跟随配置变更，Load 返回 T 的零值
+func Bind[T any](config *Config, namespace string) (*Binding[T], error) {
+	namespace = normalizeNamespace(namespace)
+	b := &Binding[T]{namespace: namespace}
+	b.v.Store(new(T))
+
+	b.remove = config.addListener(func(notice *Notice) {
+		if notice.Namespace != b.namespace {
+			return
+		}
+		if err := b.reload(notice.NewValues); err != nil {
+			b.lock.Lock()
+			onError := b.onError
+			b.lock.Unlock()
+			if onError != nil {
+				// 异步回调，避免阻塞通知协程
+				go onError(err)
+			} else {
+				logger.Errorf("reload binding of namespace %s fail, keep previous value, err: %v", b.namespace, err)
+			}
+		}
+	})
+
+	cache, ok := config.getCache(namespace)
+	if !ok {
+		b.Close()
+		return b, fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
+	}
+	b.reloadLock.Lock()
+	defer b.reloadLock.Unlock()
+	cache.lock.RLock()
+	kv := cache.v
+	cache.lock.RUnlock()
+	if err := b.store(kv); err != nil {
+		b.Close()
+		return b, err
+	}
+	return b, nil
+}
+
+// Load 返回最近一次成功解析的值
+func (b *Binding[T]) Load() T {
+	return *b.v.Load().(*T)
+}
+
+// Close 停止跟随配置变更，之后 Load 返回最后一次解析的值
+func (b *Binding[T]) Close() {
+	b.remove()
+}
+
+// OnError 设置配置变更后解析失败时的回调，回调在新的协程中执行
+func (b *Binding[T]) OnError(fn func(error)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.onError = fn
+}
+
+func (b *Binding[T]) reload(kv map[string]string) error {
+	b.reloadLock.Lock()
+	defer b.reloadLock.Unlock()
+	return b.store(kv)
+}
+
+func (b *Binding[T]) store(kv map[string]string) error {
+	v := new(T)
+	if err := decode(kv, v); err != nil {
+		return err
+	}
+	b.v.Store(v)
+	return nil
+}
@@ -0,0 +1,84 @@
+package apollo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_bind", "application", map[string]string{"db.port": "3306"})
+
+	c := f.newClient(t, Options{AppID: "app_bind"})
+
+	type appConfig struct {
+		DB dbConfig `apollo:"db"`
+	}
+	b, err := Bind[appConfig](c, "application")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if got := b.Load().DB.Port; got != 3306 {
+		t.Fatalf("port = %d, want 3306", got)
+	}
+
+	errs := make(chan error, 1)
+	b.OnError(func(err error) { errs <- err })
+
+	f.set("app_bind", "application", map[string]string{"db.port": "3307"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if got := b.Load().DB.Port; got != 3307 {
+		t.Errorf("port = %d after change, want 3307", got)
+	}
+
+	f.set("app_bind", "application", map[string]string{"db.port": "bad"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Error("bad decode did not reach OnError")
+	}
+	if got := b.Load().DB.Port; got != 3307 {
+		t.Errorf("port = %d after bad decode, want previous 3307", got)
+	}
+
+	b.Close()
+	f.set("app_bind", "application", map[string]string{"db.port": "3308"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if got := b.Load().DB.Port; got != 3307 {
+		t.Errorf("port = %d after Close, want 3307", got)
+	}
+	if n := c.listenerCount(); n != 0 {
+		t.Errorf("%d listeners left after Close", n)
+	}
+}
+
+func TestBind_Error(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_bind_err", "application", map[string]string{"name": "v"})
+
+	c := f.newClient(t, Options{AppID: "app_bind_err", Retry: RetryPolicy{MaxAttempts: 1}})
+
+	// T 为接口类型时解析失败，Load 返回 nil 而不是 panic
+	b, err := Bind[fmt.Stringer](c, "application")
+	if err == nil {
+		t.Error("expected error binding an interface type")
+	}
+	if v := b.Load(); v != nil {
+		t.Errorf("Load = %v, want nil", v)
+	}
+
+	if _, err := Bind[struct{ Name string }](c, "missing"); err == nil {
+		t.Error("expected error binding a missing namespace")
+	}
+	if n := c.listenerCount(); n != 0 {
+		t.Errorf("%d listeners left after failed Bind", n)
+	}
+}
@@ -1,17 +1,83 @@
 package apollo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 type conf struct {
-	env, appID, cluster, namespace, server string
+	env, appID, cluster, namespace string
+	ip, label, dataCenter          string // 上报给配置中心的灰度发布匹配参数
 }
 
+// Options 客户端实例的启动参数
+type Options struct {
+	AppID string
+	// Env 取值为 ENV_DEV / ENV_FAT / ENV_UAT / ENV_PRO
+	Env string
+	// Cluster 为空时使用 default
+	Cluster string
+	// Namespace 默认命名空间，为空时使用 application
+	Namespace string
+	// MetaServer 多个以逗号分隔，为空时按 Env 从 SetMetaServer 注册的地址中查找
+	MetaServer string
+	// Discovery 服务发现方式，默认 DiscoveryServices
+	Discovery DiscoveryMode
+	// ConfigService 直连的 config service 地址，多个以逗号分隔，设置后不再访问 meta server；
+	// 为空时读取环境变量 APOLLO_CONFIG_SERVICE
+	ConfigService string
+	// AccessKeySecret 当前 appID 的访问密钥（apollo.accesskey.secret），
+	// 为空时读取环境变量 APOLLO_ACCESS_KEY_SECRET
+	AccessKeySecret string
+	// AccessKeySecrets 按 appID 配置的访问密钥，优先于 AccessKeySecret
+	AccessKeySecrets map[string]string
+	// HTTPClient 自定义 http.Client，其 Timeout 会被下面各类请求的超时覆盖
+	HTTPClient *http.Client
+	// Transport HTTPClient 为空时使用的 RoundTripper
+	Transport http.RoundTripper
+	// NotifyTimeout 通知长轮询的超时，需大于服务端 60s 的挂起时间，默认 90s
+	NotifyTimeout time.Duration
+	// ConfigTimeout 拉取配置的超时，默认 5s
+	ConfigTimeout time.Duration
+	// DiscoveryTimeout 服务发现的超时，默认 5s
+	DiscoveryTimeout time.Duration
+	// TLS HTTPS 及双向 TLS 设置，应用于 HTTPClient/Transport 的 *http.Transport 副本
+	TLS *TLSOptions
+	// Retry 服务发现、配置拉取及通知长轮询的重试策略
+	Retry RetryPolicy
+	// NamespaceAliases 本实例的命名空间别名，优先于 RegisterNamespaceAlias 注册的全局别名
+	NamespaceAliases map[string]string
+	// PreloadAliases 启动时拉取 NamespaceAliases 中别名对应的命名空间并加入通知
+	PreloadAliases bool
+	// Label 灰度发布规则匹配的标签，为空时读取环境变量 APOLLO_LABEL
+	Label string
+	// IP 上报给配置中心的客户端 IP，为空时使用 LocalIP()
+	IP string
+	// DataCenter 客户端所在的数据中心，为空时读取环境变量 IDC 或 /opt/settings/server.properties 的 idc；
+	// 配置中心按 Cluster、数据中心、default 的顺序查找命名空间，实际的 cluster 见 ServedCluster
+	DataCenter string
+	// PublicNamespaces 关联的 public 命名空间 -> 所属 appID，
+	// 配置从所属 appID 拉取，再用本 appID 下同名命名空间的配置覆盖
+	PublicNamespaces map[string]string
+}
+
+const (
+	defaultNotifyTimeout    = 90 * time.Second
+	defaultConfigTimeout    = 5 * time.Second
+	defaultDiscoveryTimeout = 5 * time.Second
+)
+
+const (
+	envConfigService = "APOLLO_CONFIG_SERVICE"
+	envLabel         = "APOLLO_LABEL"
+)
+
 var (
 	defaultConf = &conf{
 		cluster:   "default",
@@ -35,19 +101,50 @@ func SetAppIDAndEnv(appID, envName string) {
 
 }
 
+// SetMetaServer 注册各环境的 meta server，同一环境的多个地址以逗号分隔
 func SetMetaServer(m map[string]string) {
-	metaServer = m
+	servers := make(map[string][]string, len(m))
+	for env, addr := range m {
+		servers[env] = splitAddrs(addr)
+	}
+	metaServer = servers
+}
+
+// SetMetaServers 注册各环境的 meta server，同一环境的多个地址依次尝试
+func SetMetaServers(m map[string][]string) {
+	servers := make(map[string][]string, len(m))
+	for env, addrs := range m {
+		servers[env] = splitAddrs(strings.Join(addrs, ","))
+	}
+	metaServer = servers
+}
+
+// splitAddrs 拆分逗号分隔的地址列表，忽略空项
+func splitAddrs(s string) []string {
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(s, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
 }
 
 func Start() error {
-	return startWithCluster(defaultConf.appID, defaultConf.env, "default")
+	return StartContext(context.Background())
+}
+
+// StartContext 同 Start，ctx 取消后默认客户端的后台协程随之退出
+func StartContext(ctx context.Context) error {
+	return startWithCluster(ctx, defaultConf.appID, defaultConf.env, "default")
 }
 
 func start(appID, envName string) error {
-	return startWithCluster(appID, envName, "default")
+	return startWithCluster(context.Background(), appID, envName, "default")
 }
 
-func startWithCluster(appID, envName, cluster string) error {
+func startWithCluster(ctx context.Context, appID, envName, cluster string) error {
 
 	defer func() {
 		if err := recover(); err != nil {
@@ -58,67 +155,198 @@ func startWithCluster(appID, envName, cluster string) error {
 	defaultConf.appID = appID
 	defaultConf.env = envName
 	defaultConf.cluster = cluster
-	if defaultConf.env != "" {
-		url, ok := metaServer[defaultConf.env]
-		if ok {
-			defaultConf.server = url
-		}
+
+	return startWithOptions(ctx, Options{
+		AppID:     defaultConf.appID,
+		Env:       defaultConf.env,
+		Cluster:   defaultConf.cluster,
+		Namespace: defaultConf.namespace,
+	})
+}
+
+func startWithOptions(ctx context.Context, opts Options) error {
+	config, err := NewClientContext(ctx, opts)
+	if err != nil {
+		return err
 	}
+	defaultConfig = config
+	return nil
+}
+
+// NewClient 创建一个独立的客户端实例，拥有自己的配置、服务发现、通知状态、缓存及后台协程，
+// 同一进程内可以同时连接多个 appID/env/cluster
+func NewClient(opts Options) (*Config, error) {
+	return NewClientContext(context.Background(), opts)
+}
 
-	if defaultConf.appID == "" {
-		return fmt.Errorf("app.id not define")
+// NewClientContext 同 NewClient，ctx 取消或调用 Close 后后台协程退出
+func NewClientContext(ctx context.Context, opts Options) (*Config, error) {
+	c := &conf{
+		env:       opts.Env,
+		appID:     opts.AppID,
+		cluster:   opts.Cluster,
+		namespace: opts.Namespace,
+
+		ip:         opts.IP,
+		label:      opts.Label,
+		dataCenter: opts.DataCenter,
+	}
+	if c.label == "" {
+		c.label = os.Getenv(envLabel)
+	}
+	if c.dataCenter == "" {
+		c.dataCenter = readDataCenter()
+	}
+	metas := splitAddrs(opts.MetaServer)
+	if len(metas) == 0 && c.env != "" {
+		metas = metaServer[c.env]
+	}
+
+	if c.appID == "" {
+		return nil, fmt.Errorf("app.id not define")
 	}
 
-	if defaultConf.env == "" {
-		return fmt.Errorf("env not define")
+	if c.env == "" {
+		return nil, fmt.Errorf("env not define")
 	}
 
-	if defaultConf.cluster == "" {
-		defaultConf.cluster = "default"
+	if c.cluster == "" {
+		c.cluster = "default"
 	}
 
-	logger.Infof("start config with %+v", *defaultConf)
+	if c.namespace == "" {
+		c.namespace = "application"
+	}
+	c.namespace = normalizeNamespace(c.namespace)
 
-	server := configServer{}
+	logger.Infof("start config with %+v, meta server: %v", *c, metas)
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Transport: opts.Transport}
+	}
+	if opts.TLS != nil {
+		var err error
+		client, err = opts.TLS.applyTLS(client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server := configServer{
+		mode:        opts.Discovery,
+		metaServers: metas,
+		client:      withTimeout(client, opts.DiscoveryTimeout, defaultDiscoveryTimeout),
+		retry:       opts.Retry.withDefaults(),
+	}
+	configService := opts.ConfigService
+	if configService == "" {
+		configService = os.Getenv(envConfigService)
+	}
+	if configService != "" {
+		server.setConfigServices(splitAddrs(configService))
+	}
 
 	no := notify{
-		notifications: make(map[string]int),
+		notifications: make(map[string]int64),
 	}
 
-	no.put(defaultConf.namespace, -1)
+	no.put(c.namespace, -1)
 
+	ctx, cancel := context.WithCancel(ctx)
 	config := &Config{
-		conf:   defaultConf,
+		conf:   c,
 		server: &server,
 		notify: &no,
 		nCache: make(map[string]*cache),
+		ctx:    ctx,
+		cancel: cancel,
+		retry:  opts.Retry.withDefaults(),
+
+		configClient: withTimeout(client, opts.ConfigTimeout, defaultConfigTimeout),
+		notifyClient: withTimeout(client, opts.NotifyTimeout, defaultNotifyTimeout),
+	}
+	config.secrets = make(map[string]string, len(opts.AccessKeySecrets)+1)
+	secret := opts.AccessKeySecret
+	if secret == "" {
+		secret = os.Getenv(envAccessKeySecret)
+	}
+	if secret != "" {
+		config.secrets[c.appID] = secret
+	}
+	for appID, secret := range opts.AccessKeySecrets {
+		config.secrets[appID] = secret
+	}
+	config.aliases = make(map[string]string, len(opts.NamespaceAliases))
+	for alias, ns := range opts.NamespaceAliases {
+		config.aliases[alias] = normalizeNamespace(ns)
+	}
+	config.publicOwners = make(map[string]string, len(opts.PublicNamespaces))
+	config.notifiers = make(map[string]*notify)
+	config.layers = make(map[string]*publicLayers)
+	for ns, owner := range opts.PublicNamespaces {
+		if owner == "" || owner == c.appID {
+			continue
+		}
+		ns = normalizeNamespace(ns)
+		config.publicOwners[ns] = owner
+		n, ok := config.notifiers[owner]
+		if !ok {
+			n = &notify{notifications: make(map[string]int64)}
+			config.notifiers[owner] = n
+		}
+		// 所属 appID 和本 appID 的变更都需要通知
+		n.put(ns, -1)
+		no.put(ns, -1)
 	}
 
 	//启动第一次获取配置
-	err := server.updateServers(defaultConf)
+	err := server.updateServers(ctx, c)
 	if err != nil {
 		logger.Warnf("get meta servers fail ,try to get config from local, err: %v", err)
 		err = loadFromLocal(config)
 		if err != nil {
 			logger.Errorf("get config from local fail, err: %v", err)
-			return err
+			cancel()
+			return nil, err
 		}
 	}
 
 	//默认初始化 application 命名空间的配置
-	err = config.updateConfig(defaultConf.namespace)
+	err = config.updateConfig(c.namespace)
 	if err != nil {
 		logger.Warnf("updateConfig failed, err: %v\n", err)
 		err = loadFromLocal(config)
 		if err != nil {
 			logger.Errorf("loadFromLocal failed, err: %v\n", err)
-			return err
+			cancel()
+			return nil, err
 		}
 	}
-	go config.doNotify()
-	go config.doUpdateMeta()
-	defaultConfig = config
-	return nil
+	if opts.PreloadAliases {
+		config.preloadAliases()
+	}
+	config.wg.Add(1)
+	go config.doNotify(c.appID, config.notify)
+	for owner, n := range config.notifiers {
+		config.wg.Add(1)
+		go config.doNotify(owner, n)
+	}
+	if !server.direct {
+		config.wg.Add(1)
+		go config.doUpdateMeta()
+	}
+	return config, nil
+}
+
+// withTimeout 复制 client 并设置超时，timeout 为 0 时使用 def
+func withTimeout(client *http.Client, timeout, def time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = def
+	}
+	c := *client
+	c.Timeout = timeout
+	return &c
 }
 
 func loadFromLocal(config *Config) error {
@@ -132,19 +360,23 @@ func loadFromLocal(config *Config) error {
 		return err
 	}
 
-	return unmarshalData(d, config, defaultConf.namespace)
+	return unmarshalData(d, config, config.conf.namespace)
 }
 
 type cf struct {
-	AppId string `json:"app.id,omitempty"`
-	Env   string `json:"env,omitempty"`
+	AppId   string            `json:"app.id,omitempty"`
+	Env     string            `json:"env,omitempty"`
+	Aliases map[string]string `json:"namespace.aliases,omitempty"`
+	Preload bool              `json:"namespace.preload,omitempty"`
 }
 
-// 从文件中读取app.id及env
+// 从文件中读取app.id、env及命名空间别名
 // 格式如下
 // {
 // "app.id":"SampleApp",
-// "env":"DEV"
+// "env":"DEV",
+// "namespace.aliases":{"global":"team.global.settings"},
+// "namespace.preload":true
 // }
 func StartWithFile(file string) error {
 	f, err := os.Open(file)
@@ -157,5 +389,15 @@ func StartWithFile(file string) error {
 	if err != nil {
 		return err
 	}
-	return start(res.AppId, res.Env)
+	defaultConf.appID = res.AppId
+	defaultConf.env = res.Env
+	defaultConf.cluster = "default"
+	return startWithOptions(context.Background(), Options{
+		AppID:            defaultConf.appID,
+		Env:              defaultConf.env,
+		Cluster:          defaultConf.cluster,
+		Namespace:        defaultConf.namespace,
+		NamespaceAliases: res.Aliases,
+		PreloadAliases:   res.Preload,
+	})
 }
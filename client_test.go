@@ -1,8 +1,14 @@
 package apollo
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const ProjectKey = "project"
@@ -166,3 +172,120 @@ func BenchmarkConfig_GetString(b *testing.B) {
 		c.GetString("sample_string")
 	}
 }
+
+func TestNewClient_Independent(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_a", "application", map[string]string{"name": "a"})
+	f.set("app_b", "application", map[string]string{"name": "b"})
+
+	a := f.newClient(t, Options{AppID: "app_a"})
+	b := f.newClient(t, Options{AppID: "app_b"})
+
+	if v := a.GetStringValue("name", ""); v != "a" {
+		t.Errorf("client a got %q, want a", v)
+	}
+	if v := b.GetStringValue("name", ""); v != "b" {
+		t.Errorf("client b got %q, want b", v)
+	}
+}
+
+func TestConfig_Close(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_close", "application", map[string]string{"name": "v1"})
+
+	c := f.newClient(t, Options{AppID: "app_close"})
+
+	done := make(chan struct{})
+	go func() {
+		_ = c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close did not stop background goroutines")
+	}
+
+	f.set("app_close", "application", map[string]string{"name": "v2"})
+	if v := c.GetStringValue("name", ""); v != "v1" {
+		t.Errorf("got %q after close, want last snapshot v1", v)
+	}
+}
+
+func TestStartContext_Cancel(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_start_ctx", "application", map[string]string{"name": "v1"})
+
+	oldMeta, oldConf, oldConfig := metaServer, *defaultConf, defaultConfig
+	t.Cleanup(func() {
+		metaServer, *defaultConf, defaultConfig = oldMeta, oldConf, oldConfig
+	})
+	metaServer = map[string][]string{ENV_DEV: {f.URL}}
+	defaultConf.appID, defaultConf.env = "app_start_ctx", ENV_DEV
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := StartContext(ctx); err != nil {
+		cancel()
+		t.Fatalf("start: %v", err)
+	}
+	c := defaultConfig
+	if v := c.GetStringValue("name", ""); v != "v1" {
+		t.Errorf("got %q, want v1", v)
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("canceling ctx did not stop background goroutines")
+	}
+}
+
+type countingTransport struct {
+	lock  sync.Mutex
+	paths []string
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	c.paths = append(c.paths, req.URL.Path)
+	c.lock.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewClient_TransportAndTimeout(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_transport", "application", map[string]string{"name": "v"})
+
+	tr := &countingTransport{}
+	c := f.newClient(t, Options{AppID: "app_transport",
+		Transport: tr, ConfigTimeout: 100 * time.Millisecond, Retry: RetryPolicy{MaxAttempts: 1}})
+
+	tr.lock.Lock()
+	paths := strings.Join(tr.paths, ",")
+	tr.lock.Unlock()
+	if !strings.Contains(paths, "/services/config") || !strings.Contains(paths, "/configs/") {
+		t.Errorf("requests did not go through the injected transport: %s", paths)
+	}
+
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+	c.server.(*configServer).setConfigServices([]string{hang.URL})
+
+	start := time.Now()
+	if err := c.updateConfig("application"); err == nil {
+		t.Error("expected timeout error from hanging config service")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("config fetch took %s, want ConfigTimeout to apply", d)
+	}
+	// 先停止长轮询，hang.Close 才不会等待挂起的请求
+	c.Close()
+}
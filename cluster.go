@@ -0,0 +1,57 @@
+package apollo
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+const (
+	defaultCluster = "default"
+	envIDC         = "IDC"
+)
+
+// serverPropertiesPath 机器级配置文件，idc 表示所在的数据中心
+var serverPropertiesPath = "/opt/settings/server.properties"
+
+// readDataCenter 依次从环境变量 IDC 及 server.properties 的 idc 读取数据中心
+func readDataCenter() string {
+	if idc := strings.TrimSpace(os.Getenv(envIDC)); idc != "" {
+		return idc
+	}
+	f, err := os.Open(serverPropertiesPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			continue
+		}
+		if strings.TrimSpace(line[:i]) == "idc" {
+			return strings.TrimSpace(line[i+1:])
+		}
+	}
+	return ""
+}
+
+// ServedCluster 返回实际提供命名空间当前配置的 cluster，尚未加载时返回空串。
+// 请求携带数据中心，配置中心按 cluster、数据中心、default 的顺序查找命名空间，返回找到的 cluster
+func (config *Config) ServedCluster(namespace string) string {
+	namespace = normalizeNamespace(namespace)
+	config.lock.RLock()
+	defer config.lock.RUnlock()
+	cache, ok := config.nCache[namespace]
+	if !ok {
+		return ""
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	return cache.cluster
+}
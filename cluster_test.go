@@ -0,0 +1,68 @@
+package apollo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_ClusterFallback(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_cluster", "application", map[string]string{"name": "custom"})
+	f.setCluster("app_cluster", "application", "custom")
+	f.set("app_cluster", "svc.idc", map[string]string{"name": "idc"})
+	f.setCluster("app_cluster", "svc.idc", "idc-a")
+	f.set("app_cluster", "svc.default", map[string]string{"name": "default"})
+	f.setCluster("app_cluster", "svc.default", "default")
+
+	c := f.newClient(t, Options{AppID: "app_cluster",
+		Cluster: "custom", DataCenter: "idc-a"})
+
+	for ns, want := range map[string]string{"application": "custom", "svc.idc": "idc", "svc.default": "default"} {
+		if v := c.GetNamespace(ns).GetString("name", ""); v != want {
+			t.Errorf("%s name = %q, want %q", ns, v, want)
+		}
+		if want == "idc" {
+			want = "idc-a"
+		}
+		if cluster := c.ServedCluster(ns); cluster != want {
+			t.Errorf("%s served by %q, want %q", ns, cluster, want)
+		}
+		if c.IsGrayRelease(ns) {
+			t.Errorf("%s should not be gray", ns)
+		}
+	}
+	if cluster := c.ServedCluster("svc.missing"); cluster != "" {
+		t.Errorf("missing namespace served by %q", cluster)
+	}
+
+	// 配置中心已按 cluster、数据中心、default 查找，404 后不再逐个 cluster 重试
+	c.GetNamespace("svc.missing").GetString("name", "")
+	if n := f.requestCount("/configs/app_cluster/custom/svc.missing"); n != 1 {
+		t.Errorf("missing namespace requested %d times, want 1", n)
+	}
+	for _, cluster := range []string{"idc-a", "default"} {
+		if n := f.requestCount("/configs/app_cluster/" + cluster + "/svc.missing"); n != 0 {
+			t.Errorf("missing namespace retried in cluster %s", cluster)
+		}
+	}
+}
+
+func TestReadDataCenter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.properties")
+	if err := os.WriteFile(path, []byte("# comment\nenv=DEV\nidc = idc-b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := serverPropertiesPath
+	serverPropertiesPath = path
+	defer func() { serverPropertiesPath = old }()
+
+	t.Setenv(envIDC, "")
+	if idc := readDataCenter(); idc != "idc-b" {
+		t.Errorf("idc from file = %q", idc)
+	}
+	t.Setenv(envIDC, "idc-env")
+	if idc := readDataCenter(); idc != "idc-env" {
+		t.Errorf("idc from env = %q", idc)
+	}
+}
@@ -1,16 +1,18 @@
 package apollo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/user"
-	"runtime"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -25,7 +27,26 @@ type Config struct {
 	nCache     map[string]*cache
 	lock       sync.RWMutex
 	handlers   []Handler
-	lastUpdate time.Time
+	lastUpdate int64 // 最近一次拉取配置的 UnixNano，原子读写
+
+	listenerLock sync.RWMutex
+	listeners    []*listener // 写时复制，通知时无需持锁
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	secrets map[string]string // appID -> 访问密钥
+	aliases map[string]string // 别名 -> 命名空间
+
+	publicOwners map[string]string  // 关联的 public 命名空间 -> 所属 appID
+	notifiers    map[string]*notify // 所属 appID -> public 命名空间的通知状态
+	layerLock    sync.Mutex
+	layers       map[string]*publicLayers
+
+	configClient *http.Client
+	notifyClient *http.Client
+	retry        RetryPolicy
 }
 
 type Handler func(notice *Notice)
@@ -90,12 +111,17 @@ func GetConfig() (config *Config, err error) {
 }
 
 func (config *Config) Watch(handler Handler) {
+	config.lock.Lock()
+	defer config.lock.Unlock()
 	config.handlers = append(config.handlers, handler)
 }
 
 type cache struct {
-	lock sync.RWMutex
-	v    map[string]string
+	lock       sync.RWMutex
+	v          map[string]string
+	content    string // 非 properties 格式命名空间的原始内容
+	releaseKey string
+	cluster    string // 配置中心返回的 cluster
 }
 
 type configuration struct {
@@ -103,56 +129,153 @@ type configuration struct {
 	Cluster       string            `json:"cluster,omitempty"`
 	NameSpace     string            `json:"namespaceName,omitempty"`
 	Configuration map[string]string `json:"configurations,omitempty"`
+	ReleaseKey    string            `json:"releaseKey,omitempty"`
+}
+
+// ReleaseKey 返回命名空间当前配置的发布版本，尚未加载时返回空串
+func (config *Config) ReleaseKey(namespace string) string {
+	namespace = normalizeNamespace(namespace)
+	config.lock.RLock()
+	defer config.lock.RUnlock()
+	cache, ok := config.nCache[namespace]
+	if !ok {
+		return ""
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	return cache.releaseKey
+}
+
+// IsGrayRelease 返回命名空间当前配置是否来自灰度发布，尚未加载时返回 false
+func (config *Config) IsGrayRelease(namespace string) bool {
+	namespace = normalizeNamespace(namespace)
+	config.lock.RLock()
+	defer config.lock.RUnlock()
+	cache, ok := config.nCache[namespace]
+	if !ok {
+		return false
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	return config.conf.isGrayCluster(cache.cluster)
 }
 
 func (config *Config) updateConfig(namespace string) error {
+	return config.updateConfigWith(config.retry, namespace)
+}
 
-	c := &conf{
-		env:       defaultConf.env,
-		appID:     defaultConf.appID,
-		cluster:   defaultConf.cluster,
-		server:    defaultConf.server,
-		namespace: namespace,
+// updateConfigWith 同 updateConfig，按 retry 重试拉取失败
+func (config *Config) updateConfigWith(retry RetryPolicy, namespace string) error {
+	namespace = normalizeNamespace(namespace)
+	if owner, ok := config.publicOwners[namespace]; ok {
+		return config.updatePublicConfig(retry, namespace, owner)
 	}
 
-	url, err := config.server.getConfigUrl(c)
+	c := config.namespaceConf(config.conf.appID, namespace)
+	data, err := config.fetchConfig(retry, c, config.ReleaseKey(namespace), config.notify.getMessagesString(namespace))
 	if err != nil {
 		return err
 	}
-	rsp, err := http.Get(url)
+	if data == nil {
+		// 配置没有变化，不更新缓存也不通知
+		config.touch()
+		return nil
+	}
+
+	// 解析成功后才更新缓存并写入本地文件，错误内容不会覆盖已有配置
+	err = unmarshalData(data, config, namespace)
 	if err != nil {
+		return fmt.Errorf("%w: json parse [%s] fail: %s", ErrInvalidConfig, string(data), err.Error())
+	}
+
+	logger.Infof("Loaded lasted config from apollo success %s %s", config.conf.appID, config.conf.env)
+	config.touch()
+	return saveToFile(data, c)
+
+}
+
+// touch 记录最近一次拉取配置的时间
+func (config *Config) touch() {
+	atomic.StoreInt64(&config.lastUpdate, time.Now().UnixNano())
+}
+
+func (config *Config) sinceLastUpdate() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&config.lastUpdate)))
+}
+
+// namespaceConf 返回 appID 下指定命名空间的请求参数
+func (config *Config) namespaceConf(appID, namespace string) *conf {
+	return &conf{
+		env:       config.conf.env,
+		appID:     appID,
+		cluster:   config.conf.cluster,
+		namespace: namespace,
+
+		ip:         config.conf.ip,
+		label:      config.conf.label,
+		dataCenter: config.conf.dataCenter,
+	}
+}
+
+// fetchConfig 从配置中心拉取配置，失败时按 retry 重试，返回 200 的响应内容；304 时返回 nil, nil
+func (config *Config) fetchConfig(retry RetryPolicy, c *conf, releaseKey, messages string) ([]byte, error) {
+	var (
+		rsp *http.Response
+		url string
+	)
+	err := retry.do(config.ctx, func() (err error) {
+		rsp, url, err = config.request(config.configClient, c.appID, func(addr string) (string, error) {
+			return config.server.getConfigUrl(addr, c, releaseKey, messages)
+		})
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	defer rsp.Body.Close()
+	switch rsp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		logger.Debugf("config not modified, url: %s", url)
+		return nil, nil
+	default:
+		return nil, statusError(url, rsp)
+	}
 	data, err := ioutil.ReadAll(rsp.Body)
 
 	logger.Debugf("config data: %s %v, url: %s, status: %d", data, err, url, rsp.StatusCode)
 
 	if err != nil {
-		return err
-	}
-
-	if len(data) > 0 {
-		err = unmarshalData(data, config, namespace)
-		if err != nil {
-			return fmt.Errorf("json parse [%s] fail: %s", string(data), err.Error())
-		}
-	} else {
-		logger.Warnf("config data empty, url: %s, status: %d", url, rsp.StatusCode)
+		return nil, err
 	}
-
-	logger.Infof("Loaded lasted config from apollo success %s %s", config.conf.appID, config.conf.env)
-	config.lastUpdate = time.Now()
-	return saveToFile(data, c)
-
+	return data, nil
 }
 
 func unmarshalData(data []byte, config *Config, namespace string) error {
+	namespace = normalizeNamespace(namespace)
 	cf := configuration{}
 	err := json.Unmarshal(data, &cf)
 	if err != nil {
 		return err
 	}
+	if cf.Configuration == nil {
+		cf.Configuration = make(map[string]string)
+	}
+	kv, content, err := parseConfigurations(namespace, cf.Configuration)
+	if err != nil {
+		return err
+	}
+	old := config.storeCache(namespace, kv, content, cf.ReleaseKey, cf.Cluster)
+	config.notifyListeners(&Notice{
+		Namespace: namespace,
+		OldValues: old,
+		NewValues: kv,
+	})
+	return nil
+}
+
+// storeCache 更新命名空间的缓存并异步通知 Watch 的 handler，返回更新前的配置
+func (config *Config) storeCache(namespace string, kv map[string]string, content, releaseKey, cluster string) map[string]string {
 	config.lock.Lock()
 	defer config.lock.Unlock()
 
@@ -160,31 +283,72 @@ func unmarshalData(data []byte, config *Config, namespace string) error {
 	if ok {
 		c.lock.Lock()
 		defer c.lock.Unlock()
+		old := c.v
 		for _, v := range config.handlers {
 			f := v
 			go f(&Notice{
 				Namespace: namespace,
-				OldValues: c.v,
-				NewValues: cf.Configuration,
-			})
-		}
-		c.v = cf.Configuration
-	} else {
-		config.nCache[namespace] = &cache{
-			v: cf.Configuration,
-		}
-		for _, v := range config.handlers {
-			f := v
-			go f(&Notice{
-				Namespace: namespace,
-				OldValues: nil,
-				NewValues: cf.Configuration,
+				OldValues: old,
+				NewValues: kv,
 			})
 		}
+		c.v = kv
+		c.content = content
+		c.releaseKey = releaseKey
+		c.cluster = cluster
+		return old
+	}
+	config.nCache[namespace] = &cache{
+		v:          kv,
+		content:    content,
+		releaseKey: releaseKey,
+		cluster:    cluster,
+	}
+	for _, v := range config.handlers {
+		f := v
+		go f(&Notice{
+			Namespace: namespace,
+			OldValues: nil,
+			NewValues: kv,
+		})
 	}
 	return nil
 }
 
+type listener struct {
+	handler Handler
+}
+
+// addListener 注册内部回调，在缓存更新后同步调用，用于绑定的结构体及类型化的值；
+// 返回的 remove 注销该回调，可重复调用
+func (config *Config) addListener(handler Handler) (remove func()) {
+	l := &listener{handler: handler}
+	config.listenerLock.Lock()
+	defer config.listenerLock.Unlock()
+	listeners := make([]*listener, 0, len(config.listeners)+1)
+	config.listeners = append(append(listeners, config.listeners...), l)
+	return func() {
+		config.listenerLock.Lock()
+		defer config.listenerLock.Unlock()
+		listeners := make([]*listener, 0, len(config.listeners))
+		for _, v := range config.listeners {
+			if v != l {
+				listeners = append(listeners, v)
+			}
+		}
+		config.listeners = listeners
+	}
+}
+
+func (config *Config) notifyListeners(notice *Notice) {
+	config.listenerLock.RLock()
+	listeners := config.listeners
+	config.listenerLock.RUnlock()
+	for _, l := range listeners {
+		l.handler(notice)
+	}
+}
+
 func GetStringValue(key string, defaultValue string) string {
 	cfg, err := GetConfig()
 	if err != nil {
@@ -257,27 +421,40 @@ func (config *Config) GetAllKeysWithPrefix(prefix string) (keys []string) {
 	return
 }
 
-func (config *Config) GetAllKeysByNamespace(namespace string) (keys []string) {
+// peekCache 返回已加载的命名空间缓存，不触发拉取
+func (config *Config) peekCache(namespace string) (*cache, bool) {
 	config.lock.RLock()
+	defer config.lock.RUnlock()
 	cache, ok := config.nCache[namespace]
+	return cache, ok
+}
+
+// getCache 返回命名空间的缓存，未加载时先从配置中心拉取并加入通知。
+// 拉取在调用方的协程中同步进行，只尝试一次，失败时不等待重试
+func (config *Config) getCache(namespace string) (*cache, bool) {
+	config.lock.RLock()
+	cache, ok := config.nCache[namespace]
+	config.lock.RUnlock()
+	if ok {
+		return cache, true
+	}
+	_ = config.updateConfigWith(config.retry.once(), namespace)
+	config.lock.RLock()
+	cache, ok = config.nCache[namespace]
+	config.lock.RUnlock()
 	if !ok {
-		config.lock.RUnlock()
-		_ = config.updateConfig(namespace)
-		config.lock.RLock()
-		cache, ok = config.nCache[namespace]
-		config.lock.RUnlock()
-		if !ok {
-			return nil
-		}
-		config.notify.put(namespace, -1)
-		cache.lock.RLock()
-		defer cache.lock.RUnlock()
-		for k := range cache.v {
-			keys = append(keys, k)
-		}
-		return keys
+		return nil, false
+	}
+	config.notify.put(namespace, -1)
+	return cache, true
+}
+
+func (config *Config) GetAllKeysByNamespace(namespace string) (keys []string) {
+	namespace = normalizeNamespace(namespace)
+	cache, ok := config.getCache(namespace)
+	if !ok {
+		return nil
 	}
-	defer config.lock.RUnlock()
 	cache.lock.RLock()
 	defer cache.lock.RUnlock()
 	for k := range cache.v {
@@ -287,28 +464,11 @@ func (config *Config) GetAllKeysByNamespace(namespace string) (keys []string) {
 }
 
 func (config *Config) GetStringByNameSpace(namespace string, key string, defaultValue string) string {
-	config.lock.RLock()
-	cache, ok := config.nCache[namespace]
+	namespace = normalizeNamespace(namespace)
+	cache, ok := config.getCache(namespace)
 	if !ok {
-		config.lock.RUnlock()
-		_ = config.updateConfig(namespace)
-		config.lock.RLock()
-		cache, ok = config.nCache[namespace]
-		config.lock.RUnlock()
-		if !ok {
-			return defaultValue
-		}
-		config.notify.put(namespace, -1)
-		cache.lock.RLock()
-		defer cache.lock.RUnlock()
-		v, ok := cache.v[key]
-		if ok {
-			return v
-		} else {
-			return defaultValue
-		}
+		return defaultValue
 	}
-	defer config.lock.RUnlock()
 	cache.lock.RLock()
 	defer cache.lock.RUnlock()
 	v, ok := cache.v[key]
@@ -383,30 +543,44 @@ func (config *Config) GetInt(key string, defaultValue int) int {
 	return i
 }
 
-func (config *Config) doNotify() {
-	for {
+func (config *Config) doNotify(appID string, n *notify) {
+	defer config.wg.Done()
+	failures := 0
+	for config.ctx.Err() == nil {
 
-		err := listen(config)
-		if err != nil {
-
-			logger.Errorf("listen err: %s", err.Error())
-			//有问题休息一下，然后重试
-			time.Sleep(time.Second * 30)
+		err := listen(config, appID, n)
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if config.ctx.Err() != nil {
+			return
 		}
+		//有问题休息一下，然后重试，连续失败时等待时间指数增长
+		d := config.retry.backoff(failures)
+		failures++
+		logger.Errorf("listen err: %s, retry after %s", err.Error(), d)
+		sleepContext(config.ctx, d)
 	}
 }
 func (config *Config) doUpdateMeta() {
-
-	for {
-		time.Sleep(time.Second * 30)
-		err := config.server.updateServers(config.conf)
-		if err != nil {
+	defer config.wg.Done()
+	for sleepContext(config.ctx, time.Second*30) {
+		err := config.server.updateServers(config.ctx, config.conf)
+		if err != nil && config.ctx.Err() == nil {
 
 			logger.Errorf("updateServers failed, err: %v, conf: %+v", err, config.conf)
 		}
 	}
 }
 
+// Close 停止长轮询及服务发现的后台协程并等待其退出，之后的读取返回关闭前的最后一份配置
+func (config *Config) Close() error {
+	config.cancel()
+	config.wg.Wait()
+	return nil
+}
+
 func (config *Config) GetAllValue() (string, error) {
 
 	config.lock.RLock()
@@ -421,7 +595,8 @@ func (config *Config) GetAllValue() (string, error) {
 	return fmt.Sprintf("%v", cache.v), nil
 }
 
-func listen(config *Config) error {
+// listen 长轮询 appID 下 n 中命名空间的变更通知，public 命名空间的所属 appID 使用各自的 notify
+func listen(config *Config, appID string, n *notify) error {
 
 	defer func() {
 		if err := recover(); err != nil {
@@ -429,13 +604,14 @@ func listen(config *Config) error {
 		}
 	}()
 
-	notifyUrl, err := config.server.getNotifyUrl(config.notify, config.conf)
+	c := config.namespaceConf(appID, config.conf.namespace)
+	rsp, notifyUrl, err := config.request(config.notifyClient, appID, func(addr string) (string, error) {
+		return config.server.getNotifyUrl(addr, n, c)
+	})
 	if err != nil {
-		return err
-	}
-	rsp, err := http.Get(notifyUrl)
-	if err != nil {
-		logger.Errorf("http get '%s' err: %s", notifyUrl, err.Error())
+		if config.ctx.Err() == nil {
+			logger.Errorf("http get '%s' err: %s", notifyUrl, err.Error())
+		}
 		return err
 	}
 	//logger.Infof("listen request: %s, status: %d",
@@ -443,10 +619,16 @@ func listen(config *Config) error {
 	defer rsp.Body.Close()
 	if rsp.StatusCode == http.StatusNotModified {
 		// 超过12小时，往配置中心注册一下自己
-		if time.Since(config.lastUpdate) > 12*time.Hour {
-			config.lastUpdate = time.Now()
-			// map 遍历是安全的
+		if n == config.notify && config.sinceLastUpdate() > 12*time.Hour {
+			config.touch()
+			// 先复制命名空间列表，getCache 可能同时写入 nCache
+			config.lock.RLock()
+			names := make([]string, 0, len(config.nCache))
 			for name := range config.nCache {
+				names = append(names, name)
+			}
+			config.lock.RUnlock()
+			for _, name := range names {
 				config.updateConfig(name)
 			}
 		}
@@ -456,7 +638,7 @@ func listen(config *Config) error {
 	if rsp.StatusCode != http.StatusOK {
 		//有问题，返回等待重试
 		logger.Errorf("http get '%s' fail: %s", notifyUrl, rsp.Status)
-		return fmt.Errorf(rsp.Status)
+		return statusError(notifyUrl, rsp)
 	}
 	data, err := ioutil.ReadAll(rsp.Body)
 	if err != nil {
@@ -472,17 +654,54 @@ func listen(config *Config) error {
 	}
 
 	for _, v := range notifications {
-		config.notify.put(v.NamespaceName, v.NotificationID)
+		n.put(v.NamespaceName, v.NotificationID)
+		n.putMessages(v.NamespaceName, v.Messages)
 		config.updateConfig(v.NamespaceName)
 	}
 	return nil
 }
 
+// request 发起 GET 请求，请求失败或服务端 5xx 时换下一个 config service 实例重试，
+// 每个已发现的实例最多尝试一次；appID 配置了访问密钥时对请求签名
+func (config *Config) request(client *http.Client, appID string, getUrl func(addr string) (string, error)) (rsp *http.Response, url string, err error) {
+	addrs := config.server.getServerAddrs(config.conf)
+	for i, addr := range addrs {
+		url, err = getUrl(addr)
+		if err != nil {
+			return nil, url, err
+		}
+		var req *http.Request
+		req, err = http.NewRequestWithContext(config.ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, url, err
+		}
+		if secret := config.secrets[appID]; secret != "" {
+			signRequest(req, appID, secret)
+		}
+		rsp, err = client.Do(req)
+		if err == nil {
+			if rsp.StatusCode < http.StatusInternalServerError {
+				return rsp, url, nil
+			}
+			rsp.Body.Close()
+			err = statusError(url, rsp)
+		}
+		if config.ctx.Err() != nil {
+			break
+		}
+		logger.Warnf("request config service failed, err: %v, tried: %d/%d", err, i+1, len(addrs))
+	}
+	return nil, url, err
+}
+
 func saveToFile(bytes []byte, conf *conf) error {
 
 	fileName := getFileName(conf)
 	err := os.MkdirAll(getDir(conf), os.ModePerm)
-	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -491,24 +710,24 @@ func saveToFile(bytes []byte, conf *conf) error {
 	_, err = file.Write(bytes)
 	return err
 }
-func getDir(conf *conf) string {
-	switch runtime.GOOS {
-	case "windows":
-		return fmt.Sprintf("%s\\.apollo\\%s\\config-cache", getHomeDir(), conf.appID)
-	default:
-		return fmt.Sprintf("%s/.apollo/%s/config-cache", getHomeDir(),
-			conf.appID)
+
+// cacheDir 本地缓存的根目录，为空时使用 ~/.apollo
+var cacheDir string
+
+func getCacheRoot() string {
+	if cacheDir != "" {
+		return cacheDir
 	}
+	return filepath.Join(getHomeDir(), ".apollo")
 }
+
+func getDir(conf *conf) string {
+	return filepath.Join(getCacheRoot(), conf.appID, "config-cache")
+}
+
 func getFileName(conf *conf) string {
-	switch runtime.GOOS {
-	case "windows":
-		return fmt.Sprintf("%s\\.apollo\\%s\\config-cache\\%s+%s+%s.properties", getHomeDir(),
-			conf.appID, conf.appID, conf.cluster, conf.namespace)
-	default:
-		return fmt.Sprintf("%s/.apollo/%s/config-cache/%s+%s+%s.properties", getHomeDir(),
-			conf.appID, conf.appID, conf.cluster, conf.namespace)
-	}
+	return filepath.Join(getDir(conf), fmt.Sprintf("%s+%s+%s.properties",
+		conf.appID, conf.cluster, normalizeNamespace(conf.namespace)))
 }
 
 func getHomeDir() string {
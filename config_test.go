@@ -0,0 +1,160 @@
+package apollo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConfig_Failover(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_failover", "application", map[string]string{"name": "ok"})
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	f.setInstances(dead.URL, f.URL)
+
+	c := f.newClient(t, Options{AppID: "app_failover"})
+
+	for i := 0; i < 2; i++ {
+		if err := c.updateConfig("application"); err != nil {
+			t.Fatalf("updateConfig with one dead instance: %v", err)
+		}
+	}
+	if v := c.GetStringValue("name", ""); v != "ok" {
+		t.Errorf("got %q, want ok", v)
+	}
+}
+
+func TestConfig_ReleaseKey(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_release", "application", map[string]string{"name": "v1"})
+
+	c := f.newClient(t, Options{AppID: "app_release"})
+	if rk := c.ReleaseKey("application"); rk != "rk-1" {
+		t.Fatalf("ReleaseKey = %q, want rk-1", rk)
+	}
+
+	notices := make(chan *Notice, 10)
+	c.Watch(func(n *Notice) { notices <- n })
+
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	select {
+	case n := <-notices:
+		t.Fatalf("unexpected notice on 304: %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.set("app_release", "application", map[string]string{"name": "v2"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if rk := c.ReleaseKey("application"); rk != "rk-2" {
+		t.Errorf("ReleaseKey = %q, want rk-2", rk)
+	}
+	if v := c.GetStringValue("name", ""); v != "v2" {
+		t.Errorf("got %q, want v2", v)
+	}
+}
+
+func TestConfig_UpdateConfigErrors(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_errors", "application", map[string]string{"name": "v1"})
+
+	c := f.newClient(t, Options{AppID: "app_errors", Retry: RetryPolicy{MaxAttempts: 1}})
+
+	if err := c.updateConfig("missing"); !errors.Is(err, ErrNamespaceNotFound) {
+		t.Errorf("missing namespace err = %v, want ErrNamespaceNotFound", err)
+	}
+
+	f.lock.Lock()
+	f.secret = "s3cr3t"
+	f.lock.Unlock()
+	if err := c.updateConfig("application"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("unsigned err = %v, want ErrUnauthorized", err)
+	}
+	f.lock.Lock()
+	f.secret = ""
+	f.lock.Unlock()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "<html>oops</html>")
+	}))
+	defer broken.Close()
+	c.server.(*configServer).setConfigServices([]string{broken.URL})
+	f.set("app_errors", "application", map[string]string{"name": "v2"})
+
+	if err := c.updateConfig("application"); !errors.Is(err, ErrServerError) {
+		t.Errorf("5xx err = %v, want ErrServerError", err)
+	}
+	if v := c.GetStringValue("name", ""); v != "v1" {
+		t.Errorf("got %q after failed fetch, want cached v1", v)
+	}
+	local := &Config{conf: c.conf, nCache: make(map[string]*cache)}
+	if err := loadFromLocal(local); err != nil || local.GetStringValue("name", "") != "v1" {
+		t.Errorf("local cache was overwritten by error response: %v", err)
+	}
+	c.Close()
+}
+
+func TestConfig_GetCacheSingleAttempt(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_ondemand", "application", map[string]string{"name": "v1"})
+
+	c := f.newClient(t, Options{AppID: "app_ondemand",
+		Retry: RetryPolicy{InitialDelay: 2 * time.Second, MaxAttempts: 3}})
+
+	var fetches int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/configs/") {
+			atomic.AddInt32(&fetches, 1)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	c.server.(*configServer).setConfigServices([]string{broken.URL})
+
+	// 同步的按需拉取失败时直接返回，不在调用方协程中等待重试
+	start := time.Now()
+	if v := c.GetStringByNameSpace("other", "name", "def"); v != "def" {
+		t.Errorf("got %q, want default", v)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("on-demand fetch took %s, want no retry delay", d)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("on-demand fetch made %d requests, want 1", n)
+	}
+	c.Close()
+}
+
+func TestConfig_NotificationMessages(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_messages", "application", map[string]string{"name": "v1"})
+
+	c := f.newClient(t, Options{AppID: "app_messages"})
+
+	f.set("app_messages", "application", map[string]string{"name": "v2"})
+	deadline := time.Now().Add(3 * time.Second)
+	for c.GetStringValue("name", "") != "v2" {
+		if time.Now().After(deadline) {
+			t.Fatal("notification did not trigger a config update")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	f.lock.Lock()
+	messages := strings.Join(f.messages, "\n")
+	f.lock.Unlock()
+	if !strings.Contains(messages, `"app_messages+default+application":2`) {
+		t.Errorf("config requests did not carry notification messages: %s", messages)
+	}
+}
@@ -0,0 +1,373 @@
+package apollo
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagName = "apollo"
+
+// DecodeError 汇总 Unmarshal 过程中所有字段的错误
+type DecodeError struct {
+	Errors []error
+}
+
+func (e *DecodeError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("apollo: %d error(s) decoding config: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Is 任一字段的错误匹配 target 时返回 true，如 errors.Is(err, ErrKeyNotFound)
+func (e *DecodeError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshal 将命名空间的配置解析到 v 指向的结构体，字段通过 apollo 标签映射：
+//
+//	Addr    string        `apollo:"server.addr,required"`
+//	Timeout time.Duration `apollo:"server.timeout,default=3s"`
+//	Hosts   []string      `apollo:"hosts,default=a,b"`
+//	DB      DBConfig      `apollo:"db"` // 嵌套结构体使用 db. 前缀
+//
+// 未设置标签的字段以字段名为 key，"-" 忽略该字段；切片取逗号分隔的值或 key[i] 形式的 key，
+// map 取 key. 前缀下的所有 key。default 只支持标量、标量切片及指向它们的指针；
+// 嵌套结构体标记 required 时前缀下至少要有一个 key。所有缺失及转换错误以 *DecodeError 一并返回
+func (config *Config) Unmarshal(namespace string, v interface{}) error {
+	namespace = normalizeNamespace(namespace)
+	cache, ok := config.getCache(namespace)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
+	}
+	cache.lock.RLock()
+	kv := cache.v
+	cache.lock.RUnlock()
+	return decode(kv, v)
+}
+
+func decode(kv map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("apollo: unmarshal target must be a non-nil struct pointer, got %T", v)
+	}
+	d := &decoder{kv: kv}
+	d.decodeStruct("", rv.Elem())
+	if len(d.errs) > 0 {
+		return &DecodeError{Errors: d.errs}
+	}
+	return nil
+}
+
+type decoder struct {
+	kv   map[string]string
+	errs []error
+}
+
+type fieldTag struct {
+	key        string
+	def        string
+	hasDefault bool
+	required   bool
+	skip       bool
+}
+
+// parseTag 解析 apollo 标签，default= 之后直到下一个已知选项的内容都属于默认值（可包含逗号）
+func parseTag(field reflect.StructField) fieldTag {
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		return fieldTag{key: field.Name}
+	}
+	if tag == "-" {
+		return fieldTag{skip: true}
+	}
+	parts := strings.Split(tag, ",")
+	t := fieldTag{key: parts[0]}
+	if t.key == "" {
+		t.key = field.Name
+	}
+	inDefault := false
+	for _, p := range parts[1:] {
+		switch {
+		case p == "required":
+			t.required = true
+			inDefault = false
+		case strings.HasPrefix(p, "default="):
+			t.def = strings.TrimPrefix(p, "default=")
+			t.hasDefault = true
+			inDefault = true
+		case inDefault:
+			t.def += "," + p
+		}
+	}
+	return t
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (d *decoder) decodeStruct(prefix string, rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if _, ok := field.Tag.Lookup(tagName); !ok {
+				d.decodeStruct(prefix, fv)
+				continue
+			}
+		}
+		tag := parseTag(field)
+		if tag.skip {
+			continue
+		}
+		d.decodeField(prefix+tag.key, tag, fv)
+	}
+}
+
+func (d *decoder) decodeField(key string, tag fieldTag, fv reflect.Value) {
+	if tag.hasDefault && !hasDefault(fv.Type()) {
+		d.errs = append(d.errs, fmt.Errorf("%s: default is not supported for type %s", key, fv.Type()))
+		tag.hasDefault = false
+	}
+	if isScalar(fv.Type()) {
+		s, ok := d.kv[key]
+		if !ok {
+			if tag.hasDefault {
+				s, ok = tag.def, true
+			} else if tag.required {
+				d.errs = append(d.errs, fmt.Errorf("%s: %w", key, ErrKeyNotFound))
+				return
+			}
+		}
+		if ok {
+			if err := setValue(fv, s); err != nil {
+				d.errs = append(d.errs, malformed(key, err))
+			}
+		}
+		return
+	}
+
+	if fv.Kind() == reflect.Struct {
+		if tag.required && !d.hasPrefix(key) {
+			d.errs = append(d.errs, fmt.Errorf("%s: %w", key, ErrKeyNotFound))
+			return
+		}
+		// 嵌套结构体总是展开，以便其字段的默认值及必填校验生效
+		d.decodeStruct(key+".", fv)
+		return
+	}
+
+	found := d.hasPrefix(key)
+	if !found && tag.hasDefault {
+		switch fv.Kind() {
+		case reflect.Slice:
+			if err := d.setSlice(fv, splitList(tag.def)); err != nil {
+				d.errs = append(d.errs, malformed(key, err))
+			}
+		case reflect.Ptr:
+			// 默认值写入新分配的元素
+			elem := reflect.New(fv.Type().Elem())
+			d.decodeField(key, tag, elem.Elem())
+			fv.Set(elem)
+		}
+		return
+	}
+	if !found {
+		if tag.required {
+			d.errs = append(d.errs, fmt.Errorf("%s: %w", key, ErrKeyNotFound))
+		}
+		return
+	}
+
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		d.decodeField(key, tag, fv.Elem())
+	case reflect.Slice:
+		d.decodeSlice(key, fv)
+	case reflect.Map:
+		d.decodeMap(key, fv)
+	default:
+		d.errs = append(d.errs, fmt.Errorf("%s: unsupported type %s", key, fv.Type()))
+	}
+}
+
+// malformed 包装 key 的值转换失败的错误，可用 errors.Is(err, ErrMalformedValue) 判断
+func malformed(key string, err error) error {
+	return fmt.Errorf("%s: %w: %s", key, ErrMalformedValue, err.Error())
+}
+
+// hasPrefix key 本身、key.xxx 或 key[i] 存在时返回 true
+func (d *decoder) hasPrefix(key string) bool {
+	if _, ok := d.kv[key]; ok {
+		return true
+	}
+	for k := range d.kv {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *decoder) decodeSlice(key string, fv reflect.Value) {
+	elem := fv.Type().Elem()
+	if s, ok := d.kv[key]; ok && isScalar(elem) {
+		if err := d.setSlice(fv, splitList(s)); err != nil {
+			d.errs = append(d.errs, malformed(key, err))
+		}
+		return
+	}
+	// key[0]、key[1] ... 形式，遇到第一个缺失的下标结束
+	slice := reflect.MakeSlice(fv.Type(), 0, 0)
+	for i := 0; ; i++ {
+		itemKey := key + "[" + strconv.Itoa(i) + "]"
+		if !d.hasPrefix(itemKey) {
+			break
+		}
+		item := reflect.New(elem).Elem()
+		d.decodeField(itemKey, fieldTag{}, item)
+		slice = reflect.Append(slice, item)
+	}
+	fv.Set(slice)
+}
+
+func (d *decoder) setSlice(fv reflect.Value, items []string) error {
+	slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+	for i, s := range items {
+		if err := setValue(slice.Index(i), s); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	fv.Set(slice)
+	return nil
+}
+
+func (d *decoder) decodeMap(key string, fv reflect.Value) {
+	mt := fv.Type()
+	if mt.Key().Kind() != reflect.String || !isScalar(mt.Elem()) {
+		d.errs = append(d.errs, fmt.Errorf("%s: unsupported map type %s", key, mt))
+		return
+	}
+	m := reflect.MakeMap(mt)
+	for k, s := range d.kv {
+		if !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		item := reflect.New(mt.Elem()).Elem()
+		if err := setValue(item, s); err != nil {
+			d.errs = append(d.errs, malformed(k, err))
+			continue
+		}
+		m.SetMapIndex(reflect.ValueOf(strings.TrimPrefix(k, key+".")).Convert(mt.Key()), item)
+	}
+	fv.Set(m)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// hasDefault 类型可以使用 default 选项：标量、标量切片及指向它们的指针
+func hasDefault(t reflect.Type) bool {
+	if isScalar(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return hasDefault(t.Elem())
+	case reflect.Slice:
+		return isScalar(t.Elem())
+	}
+	return false
+}
+
+// isScalar 可以由单个字符串转换得到的类型
+func isScalar(t reflect.Type) bool {
+	if t == durationType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setValue 将字符串转换为 fv 的类型并赋值
+func setValue(fv reflect.Value, s string) error {
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package apollo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dbConfig struct {
+	Host    string        `apollo:"host,default=127.0.0.1"`
+	Port    int           `apollo:"port,required"`
+	Timeout time.Duration `apollo:"timeout,default=3s"`
+}
+
+type serviceConfig struct {
+	Name     string            `apollo:"name"`
+	Enabled  bool              `apollo:"enabled"`
+	Ratio    float64           `apollo:"ratio"`
+	Hosts    []string          `apollo:"hosts,default=a,b"`
+	Ports    []int             `apollo:"ports"`
+	Labels   map[string]string `apollo:"labels"`
+	DB       dbConfig          `apollo:"db"`
+	Replicas []dbConfig        `apollo:"replicas"`
+	Cache    *dbConfig         `apollo:"cache"`
+	Started  time.Time         `apollo:"started"`
+	Ignored  string            `apollo:"-"`
+	Version  string
+}
+
+func TestDecode(t *testing.T) {
+	kv := map[string]string{
+		"name":             "svc",
+		"enabled":          "true",
+		"ratio":            "0.5",
+		"ports":            "80, 443",
+		"labels.zone":      "sh",
+		"labels.idc":       "a",
+		"db.port":          "3306",
+		"replicas[0].port": "3307",
+		"replicas[1].port": "3308",
+		"replicas[1].host": "10.0.0.2",
+		"started":          "2024-01-02T03:04:05Z",
+		"Version":          "v1",
+		"-":                "x",
+	}
+	var c serviceConfig
+	c.Ignored = "keep"
+	if err := decode(kv, &c); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := serviceConfig{
+		Name:    "svc",
+		Enabled: true,
+		Ratio:   0.5,
+		Hosts:   []string{"a", "b"},
+		Ports:   []int{80, 443},
+		Labels:  map[string]string{"zone": "sh", "idc": "a"},
+		DB:      dbConfig{Host: "127.0.0.1", Port: 3306, Timeout: 3 * time.Second},
+		Replicas: []dbConfig{
+			{Host: "127.0.0.1", Port: 3307, Timeout: 3 * time.Second},
+			{Host: "10.0.0.2", Port: 3308, Timeout: 3 * time.Second},
+		},
+		Started: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Ignored: "keep",
+		Version: "v1",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("decode got\n%+v\nwant\n%+v", c, want)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	kv := map[string]string{
+		"enabled":    "maybe",
+		"ports":      "80,x",
+		"db.timeout": "soon",
+	}
+	var c serviceConfig
+	err := decode(kv, &c)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+	// enabled、ports、db.port 缺失、db.timeout
+	if len(de.Errors) != 4 {
+		t.Errorf("got %d errors, want 4: %v", len(de.Errors), err)
+	}
+	if !errors.Is(de.Errors[2], ErrKeyNotFound) {
+		t.Errorf("missing required key not reported as ErrKeyNotFound: %v", err)
+	}
+	if !errors.Is(err, ErrKeyNotFound) || !errors.Is(err, ErrMalformedValue) {
+		t.Errorf("errors.Is on DecodeError does not match field errors: %v", err)
+	}
+	if errors.Is(err, ErrNamespaceNotFound) {
+		t.Errorf("DecodeError unexpectedly matches ErrNamespaceNotFound")
+	}
+
+	if err := decode(kv, c); err == nil {
+		t.Error("expected error for non-pointer target")
+	}
+}
+
+func TestDecode_PointerAndNested(t *testing.T) {
+	var c struct {
+		P     *int      `apollo:"p,default=5"`
+		Q     *[]string `apollo:"q,default=x,y"`
+		Set   *int      `apollo:"set,default=5"`
+		Unset *int      `apollo:"unset"`
+	}
+	if err := decode(map[string]string{"set": "7"}, &c); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if c.P == nil || *c.P != 5 {
+		t.Errorf("P = %v, want default 5", c.P)
+	}
+	if c.Q == nil || !reflect.DeepEqual(*c.Q, []string{"x", "y"}) {
+		t.Errorf("Q = %v, want default [x y]", c.Q)
+	}
+	if c.Set == nil || *c.Set != 7 {
+		t.Errorf("Set = %v, want 7", c.Set)
+	}
+	if c.Unset != nil {
+		t.Errorf("Unset = %v, want nil", *c.Unset)
+	}
+
+	var m struct {
+		Labels map[string]string `apollo:"labels,default=a"`
+	}
+	if err := decode(map[string]string{}, &m); err == nil {
+		t.Error("expected error for default on a map field")
+	}
+
+	var n struct {
+		DB  dbConfig  `apollo:"db,required"`
+		Opt *dbConfig `apollo:"opt,required"`
+	}
+	err := decode(map[string]string{}, &n)
+	var de *DecodeError
+	if !errors.As(err, &de) || len(de.Errors) != 2 || !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("err = %v, want ErrKeyNotFound for db and opt", err)
+	}
+	if err := decode(map[string]string{"db.port": "3306", "opt.port": "3307"}, &n); err != nil {
+		t.Errorf("decode present nested structs: %v", err)
+	}
+}
+
+func TestConfig_Unmarshal(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_unmarshal", "application", map[string]string{"name": "svc"})
+	f.set("app_unmarshal", "db.yaml", map[string]string{contentKey: "db:\n  port: 3306\nhosts: [a, b]\n"})
+
+	c := f.newClient(t, Options{AppID: "app_unmarshal"})
+
+	var cfg struct {
+		Hosts []string `apollo:"hosts"`
+		DB    dbConfig `apollo:"db"`
+	}
+	if err := c.Unmarshal("db.yaml", &cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if cfg.DB.Port != 3306 || len(cfg.Hosts) != 2 {
+		t.Errorf("got %+v", cfg)
+	}
+	if err := c.Unmarshal("missing", &cfg); !errors.Is(err, ErrNamespaceNotFound) {
+		t.Errorf("missing namespace err = %v", err)
+	}
+}
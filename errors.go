@@ -0,0 +1,40 @@
+package apollo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNamespaceNotFound 命名空间不存在或未发布（HTTP 404）
+	ErrNamespaceNotFound = errors.New("apollo: namespace not found")
+	// ErrUnauthorized 访问密钥缺失或签名错误（HTTP 401/403）
+	ErrUnauthorized = errors.New("apollo: unauthorized")
+	// ErrServerError config service 内部错误（HTTP 5xx）
+	ErrServerError = errors.New("apollo: config service error")
+	// ErrUnexpectedStatus 其他非预期的 HTTP 状态码
+	ErrUnexpectedStatus = errors.New("apollo: unexpected status")
+	// ErrInvalidConfig 返回内容无法解析
+	ErrInvalidConfig = errors.New("apollo: invalid config data")
+	// ErrKeyNotFound 配置项不存在
+	ErrKeyNotFound = errors.New("apollo: key not found")
+	// ErrMalformedValue 配置项的值无法转换为目标类型
+	ErrMalformedValue = errors.New("apollo: malformed value")
+)
+
+// statusError 将非 200/304 的响应转为可用 errors.Is 判断的错误
+func statusError(url string, rsp *http.Response) error {
+	var err error
+	switch {
+	case rsp.StatusCode == http.StatusNotFound:
+		err = ErrNamespaceNotFound
+	case rsp.StatusCode == http.StatusUnauthorized || rsp.StatusCode == http.StatusForbidden:
+		err = ErrUnauthorized
+	case rsp.StatusCode >= http.StatusInternalServerError:
+		err = ErrServerError
+	default:
+		err = ErrUnexpectedStatus
+	}
+	return fmt.Errorf("%w: http get '%s' fail: %s", err, url, rsp.Status)
+}
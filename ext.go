@@ -1,9 +1,13 @@
 package apollo
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 )
 
 type NamespaceConfig struct {
@@ -11,48 +15,130 @@ type NamespaceConfig struct {
 	Namespace string
 }
 
-func (config *Config) GlobalSettings() *NamespaceConfig {
-	return config.GetNamespace("westudy.global.settings")
-}
-
-func (config *Config) GrpcMiddleware() *NamespaceConfig {
-	return config.GetNamespace("westudy.grpc.middleware")
-}
-
 func (config *Config) GetNamespace(ns string) *NamespaceConfig {
 	return &NamespaceConfig{
 		conf:      config,
-		Namespace: ns,
+		Namespace: normalizeNamespace(ns),
 	}
 }
 
+// lookup 读取命名空间中的 key，未加载的命名空间会先从配置中心拉取并加入通知
+func (nsConfig *NamespaceConfig) lookup(key string) (string, bool) {
+	cache, ok := nsConfig.conf.getCache(nsConfig.Namespace)
+	if !ok {
+		return "", false
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	v, ok := cache.v[key]
+	return v, ok
+}
+
 func (nsConfig *NamespaceConfig) GetString(key string, defaultValue string) string {
 	return nsConfig.conf.GetStringByNameSpace(nsConfig.Namespace, key, defaultValue)
 }
 
-// 增强版http.Get, 增加了最多3次重试（因为在istio-proxy的pod中，envoy要从控制面拉取配置而启动较晚，导致业务容器启动后请求配置中心失败）
-func httpGet(url string) ([]byte, error) {
-	var try = 0
-REQUEST:
-	try++
-	rsp, err := http.Get(url)
+func (nsConfig *NamespaceConfig) GetInt(key string, defaultValue int) int {
+	v, ok := nsConfig.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(v)
 	if err != nil {
-		if try < 5 {
-			logger.Infof("http get fail: %s, try again after one second, tried: %d", err.Error(), try)
-			time.Sleep(time.Second)
-			goto REQUEST
+		return defaultValue
+	}
+	return i
+}
+
+func (nsConfig *NamespaceConfig) GetBool(key string, defaultValue bool) bool {
+	v, ok := nsConfig.lookup(key)
+	if ok {
+		return v == "true"
+	}
+	return defaultValue
+}
+
+func (nsConfig *NamespaceConfig) GetList(key string) (array []string, ok bool) {
+	v, ok := nsConfig.lookup(key)
+	if ok {
+		array = strings.Split(v, ",")
+	}
+	return
+}
+
+func (nsConfig *NamespaceConfig) GetJson(key string, vv interface{}) (ok bool, err error) {
+	v, ok := nsConfig.lookup(key)
+	if ok {
+		err = json.Unmarshal([]byte(v), vv)
+	}
+	return
+}
+
+func (nsConfig *NamespaceConfig) GetAllKeys() (keys []string) {
+	return nsConfig.GetAllKeysWithPrefix("")
+}
+
+func (nsConfig *NamespaceConfig) GetAllKeysWithPrefix(prefix string) (keys []string) {
+	keys = make([]string, 0)
+	cache, ok := nsConfig.conf.getCache(nsConfig.Namespace)
+	if !ok {
+		return
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	for k := range cache.v {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Watch 监听本命名空间的变更，未加载的命名空间会先拉取并加入通知
+func (nsConfig *NamespaceConfig) Watch(handler Handler) {
+	namespace := nsConfig.Namespace
+	nsConfig.conf.Watch(func(notice *Notice) {
+		if notice.Namespace == namespace {
+			handler(notice)
+		}
+	})
+	nsConfig.conf.getCache(namespace)
+}
+
+// Unmarshal 同 (*Config).Unmarshal，解析本命名空间
+func (nsConfig *NamespaceConfig) Unmarshal(v interface{}) error {
+	return nsConfig.conf.Unmarshal(nsConfig.Namespace, v)
+}
+
+// 增强版http.Get, 按重试策略重试（因为在istio-proxy的pod中，envoy要从控制面拉取配置而启动较晚，导致业务容器启动后请求配置中心失败）
+func httpGet(ctx context.Context, client *http.Client, policy RetryPolicy, url string) (data []byte, err error) {
+	err = policy.do(ctx, func() error {
+		data, err = doGet(ctx, client, url)
+		if err != nil {
+			return fmt.Errorf("http get fail: %s", err.Error())
 		}
+		return nil
+	})
+	return data, err
+}
+
+func doGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
 		return nil, err
 	}
 	defer rsp.Body.Close()
 	data, err := ioutil.ReadAll(rsp.Body)
 	if err != nil {
-		if try < 5 {
-			logger.Infof("http get fail: %s, try again after one second, tried: %d", err.Error(), try)
-			time.Sleep(time.Second)
-			goto REQUEST
-		}
 		return nil, err
 	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET '%s' status: %s", url, rsp.Status)
+	}
 	return data, nil
 }
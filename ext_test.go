@@ -0,0 +1,62 @@
+package apollo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespaceConfig(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_nsconfig", "application", map[string]string{})
+	f.set("app_nsconfig", "team.public", map[string]string{
+		"n": "3", "on": "true", "list": "a,b", "json": `{"a":1}`, "db.port": "3306",
+	})
+
+	c := f.newClient(t, Options{AppID: "app_nsconfig"})
+
+	ns := c.GetNamespace("team.public")
+	notices := make(chan *Notice, 10)
+	ns.Watch(func(n *Notice) { notices <- n })
+
+	if ns.GetInt("n", 0) != 3 || !ns.GetBool("on", false) || ns.GetInt("missing", 7) != 7 {
+		t.Errorf("GetInt/GetBool mismatch")
+	}
+	if list, ok := ns.GetList("list"); !ok || len(list) != 2 {
+		t.Errorf("GetList = %v, %v", list, ok)
+	}
+	m := map[string]int{}
+	if ok, err := ns.GetJson("json", &m); !ok || err != nil || m["a"] != 1 {
+		t.Errorf("GetJson = %v, %v, %v", ok, err, m)
+	}
+	if keys := ns.GetAllKeys(); len(keys) != 5 {
+		t.Errorf("GetAllKeys = %v", keys)
+	}
+	if keys := ns.GetAllKeysWithPrefix("db."); len(keys) != 1 || keys[0] != "db.port" {
+		t.Errorf("GetAllKeysWithPrefix = %v", keys)
+	}
+	var cfg struct {
+		DB dbConfig `apollo:"db"`
+	}
+	if err := ns.Unmarshal(&cfg); err != nil || cfg.DB.Port != 3306 {
+		t.Errorf("Unmarshal = %+v, %v", cfg, err)
+	}
+
+	f.set("app_nsconfig", "application", map[string]string{"x": "1"})
+	f.set("app_nsconfig", "team.public", map[string]string{"n": "4"})
+	_ = c.updateConfig("application")
+	_ = c.updateConfig("team.public")
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case n := <-notices:
+			if n.Namespace != "team.public" {
+				t.Fatalf("got notice for %s", n.Namespace)
+			}
+			if n.NewValues["n"] == "4" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("no notice for team.public change")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package apollo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type format string
+
+const (
+	formatProperties format = "properties"
+	formatXML        format = "xml"
+	formatJSON       format = "json"
+	formatYML        format = "yml"
+	formatYAML       format = "yaml"
+	formatTXT        format = "txt"
+)
+
+// contentKey 非 properties 格式的命名空间，配置中心以单个 content 返回全文
+const contentKey = "content"
+
+// namespaceFormat 根据命名空间后缀判断格式，无后缀的为 properties
+func namespaceFormat(namespace string) format {
+	namespace = normalizeNamespace(namespace)
+	i := strings.LastIndex(namespace, ".")
+	if i < 0 {
+		return formatProperties
+	}
+	switch f := format(namespace[i+1:]); f {
+	case formatXML, formatJSON, formatYML, formatYAML, formatTXT:
+		return f
+	}
+	return formatProperties
+}
+
+// parseConfigurations 将配置中心返回的内容转为按 key 读取的视图：
+// properties 原样返回；yaml/json 解析 content 并展开为点分隔的 key（数组下标为 key[i]）；
+// xml/txt 无法展开，保留 content
+func parseConfigurations(namespace string, configurations map[string]string) (kv map[string]string, content string, err error) {
+	f := namespaceFormat(namespace)
+	if f == formatProperties {
+		return configurations, "", nil
+	}
+	content = configurations[contentKey]
+	switch f {
+	case formatJSON, formatYML, formatYAML:
+		var v interface{}
+		if err = decodeContent(f, content, &v); err != nil {
+			return nil, "", fmt.Errorf("parse %s namespace %s fail: %s", f, namespace, err.Error())
+		}
+		kv = make(map[string]string)
+		flatten("", v, kv)
+		return kv, content, nil
+	default:
+		return configurations, content, nil
+	}
+}
+
+func decodeContent(f format, content string, v interface{}) error {
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	switch f {
+	case formatJSON:
+		d := json.NewDecoder(bytes.NewReader([]byte(content)))
+		d.UseNumber()
+		return d.Decode(v)
+	case formatYML, formatYAML:
+		return yaml.Unmarshal([]byte(content), v)
+	default:
+		return fmt.Errorf("namespace format %s can not be decoded", f)
+	}
+}
+
+// flatten 将嵌套结构展开为 a.b.c / a.list[0] 形式的 key，标量数组同时以逗号拼接保存在 a.list 上
+func flatten(prefix string, v interface{}, kv map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			flatten(joinKey(prefix, k), child, kv)
+		}
+	case map[interface{}]interface{}:
+		for k, child := range t {
+			flatten(joinKey(prefix, fmt.Sprint(k)), child, kv)
+		}
+	case []interface{}:
+		scalars := make([]string, 0, len(t))
+		for i, child := range t {
+			flatten(prefix+"["+strconv.Itoa(i)+"]", child, kv)
+			if s, ok := scalarString(child); ok {
+				scalars = append(scalars, s)
+			}
+		}
+		if prefix != "" && len(scalars) == len(t) {
+			kv[prefix] = strings.Join(scalars, ",")
+		}
+	default:
+		if prefix == "" {
+			return
+		}
+		s, _ := scalarString(t)
+		kv[prefix] = s
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return t, true
+	case json.Number:
+		return t.String(), true
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return "", false
+	default:
+		return fmt.Sprint(t), true
+	}
+}
+
+// GetNamespaceContent 返回非 properties 格式（yaml/json/xml/txt）命名空间的原始内容，
+// 未加载的命名空间会先从配置中心拉取并加入通知
+func (config *Config) GetNamespaceContent(namespace string) (string, bool) {
+	namespace = normalizeNamespace(namespace)
+	if namespaceFormat(namespace) == formatProperties {
+		return "", false
+	}
+	cache, ok := config.getCache(namespace)
+	if !ok {
+		return "", false
+	}
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	return cache.content, true
+}
+
+// DecodeNamespaceContent 将 yaml/json 命名空间的内容解析到 v，字段映射遵循 encoding/json 或 yaml.v3 的规则，
+// 内容为空时不修改 v
+func (config *Config) DecodeNamespaceContent(namespace string, v interface{}) error {
+	content, ok := config.GetNamespaceContent(namespace)
+	if !ok {
+		return fmt.Errorf("namespace %s not found or not a yaml/json namespace", namespace)
+	}
+	f := namespaceFormat(namespace)
+	if f == formatJSON && strings.TrimSpace(content) != "" {
+		return json.Unmarshal([]byte(content), v)
+	}
+	return decodeContent(f, content, v)
+}
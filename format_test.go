@@ -0,0 +1,110 @@
+package apollo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamespaceFormat(t *testing.T) {
+	cases := map[string]format{
+		"application":            formatProperties,
+		"team.global.properties": formatProperties,
+		"team.global":            formatProperties,
+		"db.yaml":                formatYAML,
+		"db.YML":                 formatYML,
+		"routes.json":            formatJSON,
+		"layout.xml":             formatXML,
+		"notes.txt":              formatTXT,
+	}
+	for ns, want := range cases {
+		if got := namespaceFormat(ns); got != want {
+			t.Errorf("namespaceFormat(%q) = %q, want %q", ns, got, want)
+		}
+	}
+}
+
+func TestParseConfigurations(t *testing.T) {
+	yml := "mysql:\n  host: 10.0.0.1\n  port: 3306\nhosts: [a, b]\nusers:\n  - name: x\n"
+	kv, content, err := parseConfigurations("db.yaml", map[string]string{contentKey: yml})
+	if err != nil {
+		t.Fatalf("parse yaml: %v", err)
+	}
+	if content != yml {
+		t.Errorf("content = %q", content)
+	}
+	want := map[string]string{
+		"mysql.host":    "10.0.0.1",
+		"mysql.port":    "3306",
+		"hosts":         "a,b",
+		"hosts[0]":      "a",
+		"hosts[1]":      "b",
+		"users[0].name": "x",
+	}
+	for k, v := range want {
+		if kv[k] != v {
+			t.Errorf("kv[%q] = %q, want %q", k, kv[k], v)
+		}
+	}
+
+	kv, _, err = parseConfigurations("routes.json", map[string]string{contentKey: `{"timeout": 1.50, "on": true}`})
+	if err != nil {
+		t.Fatalf("parse json: %v", err)
+	}
+	if kv["timeout"] != "1.50" || kv["on"] != "true" {
+		t.Errorf("json kv = %v", kv)
+	}
+
+	if _, _, err := parseConfigurations("bad.json", map[string]string{contentKey: `{`}); err == nil {
+		t.Error("expected error for malformed json content")
+	}
+
+	kv, content, _ = parseConfigurations("notes.txt", map[string]string{contentKey: "hello"})
+	if content != "hello" || kv[contentKey] != "hello" {
+		t.Errorf("txt kv = %v, content = %q", kv, content)
+	}
+}
+
+func TestConfig_NamespaceContent(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_formats", "application", map[string]string{})
+	f.set("app_formats", "db.yaml", map[string]string{contentKey: "mysql:\n  host: 10.0.0.1\n  port: 3306\n"})
+
+	c := f.newClient(t, Options{AppID: "app_formats"})
+
+	if v := c.GetStringByNameSpace("db.yaml", "mysql.host", ""); v != "10.0.0.1" {
+		t.Errorf("mysql.host = %q, want 10.0.0.1", v)
+	}
+	if content, ok := c.GetNamespaceContent("db.yaml"); !ok || !strings.Contains(content, "mysql:") {
+		t.Errorf("GetNamespaceContent = %q, %v", content, ok)
+	}
+
+	var db struct {
+		MySQL struct {
+			Host string `yaml:"host"`
+			Port int    `yaml:"port"`
+		} `yaml:"mysql"`
+	}
+	if err := c.DecodeNamespaceContent("db.yaml", &db); err != nil {
+		t.Fatalf("DecodeNamespaceContent: %v", err)
+	}
+	if db.MySQL.Host != "10.0.0.1" || db.MySQL.Port != 3306 {
+		t.Errorf("decoded %+v", db)
+	}
+
+	// 内容为空时 yaml 与 json 一致，不报错也不修改 v
+	f.set("app_formats", "empty.yaml", map[string]string{contentKey: ""})
+	f.set("app_formats", "empty.json", map[string]string{contentKey: " \n"})
+	for _, ns := range []string{"empty.yaml", "empty.json"} {
+		v := map[string]string{"keep": "1"}
+		if err := c.DecodeNamespaceContent(ns, &v); err != nil {
+			t.Errorf("DecodeNamespaceContent(%s): %v", ns, err)
+		}
+		if len(v) != 1 || v["keep"] != "1" {
+			t.Errorf("DecodeNamespaceContent(%s) changed v to %v", ns, v)
+		}
+	}
+
+	if _, ok := c.GetNamespaceContent("application"); ok {
+		t.Error("properties namespace should not have raw content")
+	}
+}
@@ -0,0 +1,206 @@
+package apollo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getE 读取 key 并解析，key 不存在返回 ErrKeyNotFound，解析失败返回 ErrMalformedValue
+func getE[T any](lookup func(key string) (string, bool), key string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	v, ok := lookup(key)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	t, err := parse(v)
+	if err != nil {
+		return zero, fmt.Errorf("%w: %s=%q: %s", ErrMalformedValue, key, v, err.Error())
+	}
+	return t, nil
+}
+
+func getOr[T any](t T, err error, defaultValue T) T {
+	if err != nil {
+		return defaultValue
+	}
+	return t
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+}
+
+func parseUint64(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+}
+
+func parseFloat64(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	return time.ParseDuration(strings.TrimSpace(s))
+}
+
+func timeParser(layout string) func(string) (time.Time, error) {
+	return func(s string) (time.Time, error) {
+		return time.Parse(layout, strings.TrimSpace(s))
+	}
+}
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// parseBytesSize 解析 512、10KB、1.5GiB 形式的大小，单位不区分大小写且均按 1024 进制
+func parseBytesSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, err
+	}
+	m, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	v := n * m
+	// float64(math.MaxInt64) 即 2^63，超出 int64 范围
+	if math.IsNaN(v) || math.IsInf(v, 0) || v >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q overflows int64", s)
+	}
+	return int64(v), nil
+}
+
+func (config *Config) GetInt64(key string, defaultValue int64) int64 {
+	v, err := config.GetInt64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetInt64E(key string) (int64, error) {
+	return getE(config.GetString, key, parseInt64)
+}
+
+func (config *Config) GetUint64(key string, defaultValue uint64) uint64 {
+	v, err := config.GetUint64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetUint64E(key string) (uint64, error) {
+	return getE(config.GetString, key, parseUint64)
+}
+
+func (config *Config) GetFloat64(key string, defaultValue float64) float64 {
+	v, err := config.GetFloat64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetFloat64E(key string) (float64, error) {
+	return getE(config.GetString, key, parseFloat64)
+}
+
+// GetDuration 解析 time.ParseDuration 格式，如 300ms、1m30s
+func (config *Config) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	v, err := config.GetDurationE(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetDurationE(key string) (time.Duration, error) {
+	return getE(config.GetString, key, parseDuration)
+}
+
+// GetTime 按 layout 解析时间，如 time.RFC3339
+func (config *Config) GetTime(key string, layout string, defaultValue time.Time) time.Time {
+	v, err := config.GetTimeE(key, layout)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetTimeE(key string, layout string) (time.Time, error) {
+	return getE(config.GetString, key, timeParser(layout))
+}
+
+// GetBytesSize 解析 512、10KB、1.5GiB 形式的大小，返回字节数，单位均按 1024 进制
+func (config *Config) GetBytesSize(key string, defaultValue int64) int64 {
+	v, err := config.GetBytesSizeE(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (config *Config) GetBytesSizeE(key string) (int64, error) {
+	return getE(config.GetString, key, parseBytesSize)
+}
+
+func (nsConfig *NamespaceConfig) GetInt64(key string, defaultValue int64) int64 {
+	v, err := nsConfig.GetInt64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetInt64E(key string) (int64, error) {
+	return getE(nsConfig.lookup, key, parseInt64)
+}
+
+func (nsConfig *NamespaceConfig) GetUint64(key string, defaultValue uint64) uint64 {
+	v, err := nsConfig.GetUint64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetUint64E(key string) (uint64, error) {
+	return getE(nsConfig.lookup, key, parseUint64)
+}
+
+func (nsConfig *NamespaceConfig) GetFloat64(key string, defaultValue float64) float64 {
+	v, err := nsConfig.GetFloat64E(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetFloat64E(key string) (float64, error) {
+	return getE(nsConfig.lookup, key, parseFloat64)
+}
+
+func (nsConfig *NamespaceConfig) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	v, err := nsConfig.GetDurationE(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetDurationE(key string) (time.Duration, error) {
+	return getE(nsConfig.lookup, key, parseDuration)
+}
+
+func (nsConfig *NamespaceConfig) GetTime(key string, layout string, defaultValue time.Time) time.Time {
+	v, err := nsConfig.GetTimeE(key, layout)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetTimeE(key string, layout string) (time.Time, error) {
+	return getE(nsConfig.lookup, key, timeParser(layout))
+}
+
+func (nsConfig *NamespaceConfig) GetBytesSize(key string, defaultValue int64) int64 {
+	v, err := nsConfig.GetBytesSizeE(key)
+	return getOr(v, err, defaultValue)
+}
+
+func (nsConfig *NamespaceConfig) GetBytesSizeE(key string) (int64, error) {
+	return getE(nsConfig.lookup, key, parseBytesSize)
+}
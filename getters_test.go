@@ -0,0 +1,94 @@
+package apollo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestConfig(namespaces map[string]map[string]string) *Config {
+	c := &Config{conf: &conf{namespace: "application"}, nCache: make(map[string]*cache)}
+	for ns, kv := range namespaces {
+		c.nCache[ns] = &cache{v: kv}
+	}
+	return c
+}
+
+func TestParseBytesSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":    512,
+		"10B":    10,
+		"10KB":   10 << 10,
+		"10kb":   10 << 10,
+		"1.5GiB": 3 << 29,
+		"2 MB":   2 << 20,
+		"1T":     1 << 40,
+	}
+	for s, want := range cases {
+		got, err := parseBytesSize(s)
+		if err != nil || got != want {
+			t.Errorf("parseBytesSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "10XB", "1.2.3KB", "99999999999TB", "8388608TB"} {
+		if _, err := parseBytesSize(s); err == nil {
+			t.Errorf("parseBytesSize(%q) expected error", s)
+		}
+	}
+}
+
+func TestConfig_TypedGetters(t *testing.T) {
+	c := newTestConfig(map[string]map[string]string{
+		"application": {
+			"i64":  "-9000000000",
+			"u64":  "18000000000000000000",
+			"f64":  "0.25",
+			"dur":  "1m30s",
+			"time": "2024-01-02T03:04:05Z",
+			"size": "10MB",
+			"huge": "99999999999TB",
+			"bad":  "oops",
+		},
+		"other": {"i64": "42"},
+	})
+
+	if v := c.GetInt64("i64", 0); v != -9000000000 {
+		t.Errorf("GetInt64 = %d", v)
+	}
+	if v := c.GetUint64("u64", 0); v != 18000000000000000000 {
+		t.Errorf("GetUint64 = %d", v)
+	}
+	if v := c.GetFloat64("f64", 0); v != 0.25 {
+		t.Errorf("GetFloat64 = %v", v)
+	}
+	if v := c.GetDuration("dur", 0); v != 90*time.Second {
+		t.Errorf("GetDuration = %s", v)
+	}
+	if v := c.GetTime("time", time.RFC3339, time.Time{}); !v.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("GetTime = %s", v)
+	}
+	if v := c.GetBytesSize("size", 0); v != 10<<20 {
+		t.Errorf("GetBytesSize = %d", v)
+	}
+	if _, err := c.GetBytesSizeE("huge"); !errors.Is(err, ErrMalformedValue) {
+		t.Errorf("overflowing size err = %v, want ErrMalformedValue", err)
+	}
+	if v := c.GetDuration("bad", time.Second); v != time.Second {
+		t.Errorf("GetDuration on malformed = %s, want default", v)
+	}
+
+	if _, err := c.GetInt64E("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("missing err = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := c.GetInt64E("bad"); !errors.Is(err, ErrMalformedValue) {
+		t.Errorf("malformed err = %v, want ErrMalformedValue", err)
+	}
+
+	ns := c.GetNamespace("other")
+	if v, err := ns.GetInt64E("i64"); err != nil || v != 42 {
+		t.Errorf("namespace GetInt64E = %d, %v", v, err)
+	}
+	if _, err := ns.GetFloat64E("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("namespace missing err = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,249 @@
+package apollo
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// layerRetryInterval 未加载的层在访问时重新拉取的最小间隔
+var layerRetryInterval = 10 * time.Second
+
+// LayeredConfig 按优先级叠加多个命名空间的配置视图，同一个 key 取排在前面的命名空间的值
+type LayeredConfig struct {
+	conf       *Config
+	namespaces []string
+
+	lock     sync.Mutex
+	snapshot map[string]string // 最近一次通知时的生效值，创建完成前为 nil
+	handlers []Handler
+	remove   func()
+
+	loadLock sync.Mutex
+	attempts map[string]time.Time // 未加载的层 -> 最近一次拉取的时间
+}
+
+// Layered 创建按优先级从高到低排列的多命名空间视图，如
+// cfg.Layered("svc.override", "application", "team.public")，
+// 未加载的命名空间在创建时从配置中心拉取并加入通知，拉取失败的在之后访问时重试
+func (config *Config) Layered(namespaces ...string) *LayeredConfig {
+	l := &LayeredConfig{conf: config, attempts: make(map[string]time.Time)}
+	for _, ns := range namespaces {
+		l.namespaces = append(l.namespaces, normalizeNamespace(ns))
+	}
+	// 先注册回调再加载及取快照，快照之后的变更都会经过 onChange
+	l.remove = config.addListener(l.onChange)
+	for _, ns := range l.namespaces {
+		l.cache(ns)
+	}
+	l.lock.Lock()
+	l.snapshot = l.effective(false)
+	l.lock.Unlock()
+	return l
+}
+
+// Close 停止跟随配置变更，Watch 注册的 handler 不再收到通知
+func (l *LayeredConfig) Close() {
+	l.remove()
+}
+
+// Namespaces 返回按优先级排列的命名空间
+func (l *LayeredConfig) Namespaces() []string {
+	return append([]string(nil), l.namespaces...)
+}
+
+// cache 返回某一层的缓存，未加载时重新拉取，同一层两次拉取至少间隔 layerRetryInterval
+func (l *LayeredConfig) cache(namespace string) (*cache, bool) {
+	if c, ok := l.conf.peekCache(namespace); ok {
+		return c, true
+	}
+	l.loadLock.Lock()
+	if last, ok := l.attempts[namespace]; ok && time.Since(last) < layerRetryInterval {
+		l.loadLock.Unlock()
+		return nil, false
+	}
+	l.attempts[namespace] = time.Now()
+	l.loadLock.Unlock()
+
+	c, ok := l.conf.getCache(namespace)
+	if ok {
+		l.loadLock.Lock()
+		delete(l.attempts, namespace)
+		l.loadLock.Unlock()
+	}
+	return c, ok
+}
+
+// effective 合并各命名空间，得到每个 key 的生效值；load 为 false 时只读取已加载的层，
+// 用于通知回调中，避免在通知协程中拉取
+func (l *LayeredConfig) effective(load bool) map[string]string {
+	kv := make(map[string]string)
+	for i := len(l.namespaces) - 1; i >= 0; i-- {
+		var (
+			cache *cache
+			ok    bool
+		)
+		if load {
+			cache, ok = l.cache(l.namespaces[i])
+		} else {
+			cache, ok = l.conf.peekCache(l.namespaces[i])
+		}
+		if !ok {
+			continue
+		}
+		cache.lock.RLock()
+		for k, v := range cache.v {
+			kv[k] = v
+		}
+		cache.lock.RUnlock()
+	}
+	return kv
+}
+
+func (l *LayeredConfig) contains(namespace string) bool {
+	for _, ns := range l.namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// onChange 任一层变更时重新计算生效值，只把生效值有变化的 key 通知给 Watch 的 handler
+func (l *LayeredConfig) onChange(notice *Notice) {
+	if !l.contains(notice.Namespace) {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	old := l.snapshot
+	if old == nil {
+		// 创建中，快照尚未生成
+		return
+	}
+	current := l.effective(false)
+	l.snapshot = current
+
+	oldValues := make(map[string]string)
+	newValues := make(map[string]string)
+	for k, v := range current {
+		if o, ok := old[k]; !ok || o != v {
+			newValues[k] = v
+			if ok {
+				oldValues[k] = o
+			}
+		}
+	}
+	for k, o := range old {
+		if _, ok := current[k]; !ok {
+			oldValues[k] = o
+		}
+	}
+	if len(oldValues) == 0 && len(newValues) == 0 {
+		return
+	}
+	for _, v := range l.handlers {
+		f := v
+		go f(&Notice{
+			Namespace: notice.Namespace,
+			OldValues: oldValues,
+			NewValues: newValues,
+		})
+	}
+}
+
+// Watch 监听生效值的变更，Notice 只包含生效值有变化的 key，被删除的 key 只出现在 OldValues 中
+func (l *LayeredConfig) Watch(handler Handler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.handlers = append(l.handlers, handler)
+}
+
+// Lookup 按优先级查找 key，返回生效值及其所在的命名空间
+func (l *LayeredConfig) Lookup(key string) (v string, namespace string, ok bool) {
+	for _, ns := range l.namespaces {
+		cache, exist := l.cache(ns)
+		if !exist {
+			continue
+		}
+		cache.lock.RLock()
+		v, ok = cache.v[key]
+		cache.lock.RUnlock()
+		if ok {
+			return v, ns, true
+		}
+	}
+	return "", "", false
+}
+
+func (l *LayeredConfig) GetString(key string) (v string, ok bool) {
+	v, _, ok = l.Lookup(key)
+	return
+}
+
+func (l *LayeredConfig) GetStringValue(key string, defaultValue string) string {
+	v, ok := l.GetString(key)
+	if !ok {
+		return defaultValue
+	}
+	return v
+}
+
+func (l *LayeredConfig) GetInt(key string, defaultValue int) int {
+	v, ok := l.GetString(key)
+	if !ok {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+func (l *LayeredConfig) GetBool(key string, defaultValue bool) bool {
+	v, ok := l.GetString(key)
+	if ok {
+		return v == "true"
+	}
+	return defaultValue
+}
+
+func (l *LayeredConfig) GetList(key string) (array []string, ok bool) {
+	v, ok := l.GetString(key)
+	if ok {
+		array = strings.Split(v, ",")
+	}
+	return
+}
+
+func (l *LayeredConfig) GetJson(key string, vv interface{}) (ok bool, err error) {
+	v, ok := l.GetString(key)
+	if ok {
+		err = json.Unmarshal([]byte(v), vv)
+	}
+	return
+}
+
+// GetAllKeys 返回所有层的 key（去重）
+func (l *LayeredConfig) GetAllKeys() (keys []string) {
+	return l.GetAllKeysWithPrefix("")
+}
+
+func (l *LayeredConfig) GetAllKeysWithPrefix(prefix string) (keys []string) {
+	keys = make([]string, 0)
+	for k := range l.effective(true) {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Unmarshal 同 (*Config).Unmarshal，按生效值解析
+func (l *LayeredConfig) Unmarshal(v interface{}) error {
+	return decode(l.effective(true), v)
+}
@@ -0,0 +1,86 @@
+package apollo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Layered(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_layered", "application", map[string]string{"a": "app", "b": "app"})
+	f.set("app_layered", "svc.override", map[string]string{"a": "override"})
+	f.set("app_layered", "team.public", map[string]string{"a": "public", "b": "public", "c": "public"})
+
+	c := f.newClient(t, Options{AppID: "app_layered"})
+
+	l := c.Layered("svc.override", "application", "team.public")
+	if v, ns, _ := l.Lookup("a"); v != "override" || ns != "svc.override" {
+		t.Errorf("a = %q from %s", v, ns)
+	}
+	if v := l.GetStringValue("b", ""); v != "app" {
+		t.Errorf("b = %q, want app", v)
+	}
+	if v := l.GetStringValue("c", ""); v != "public" {
+		t.Errorf("c = %q, want public", v)
+	}
+	if keys := l.GetAllKeys(); len(keys) != 3 {
+		t.Errorf("keys = %v", keys)
+	}
+
+	notices := make(chan *Notice, 10)
+	l.Watch(func(n *Notice) { notices <- n })
+
+	// a 被 svc.override 覆盖，team.public 修改 a 不影响生效值
+	f.set("app_layered", "team.public", map[string]string{"a": "public2", "b": "public", "c": "public2"})
+	if err := c.updateConfig("team.public"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	select {
+	case n := <-notices:
+		if len(n.NewValues) != 1 || n.NewValues["c"] != "public2" || n.OldValues["c"] != "public" {
+			t.Errorf("notice = %+v, want only c changed", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no notice for effective change")
+	}
+}
+
+func TestConfig_LayeredLateLayer(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_layered_late", "application", map[string]string{"a": "app"})
+
+	c := f.newClient(t, Options{AppID: "app_layered_late", Retry: RetryPolicy{MaxAttempts: 1}})
+
+	old := layerRetryInterval
+	layerRetryInterval = time.Hour
+	t.Cleanup(func() { layerRetryInterval = old })
+
+	// svc.late 创建时还不存在
+	l := c.Layered("svc.late", "application")
+	notices := make(chan *Notice, 10)
+	l.Watch(func(n *Notice) { notices <- n })
+
+	f.set("app_layered_late", "svc.late", map[string]string{"a": "late"})
+	if v := l.GetStringValue("a", ""); v != "app" {
+		t.Errorf("a = %q within retry interval, want app", v)
+	}
+
+	// 超过重试间隔后访问时重新拉取缺失的层，并通知生效值的变化
+	layerRetryInterval = 0
+	if v, ns, _ := l.Lookup("a"); v != "late" || ns != "svc.late" {
+		t.Errorf("a = %q from %s, want late from svc.late", v, ns)
+	}
+	select {
+	case n := <-notices:
+		if n.NewValues["a"] != "late" || n.OldValues["a"] != "app" {
+			t.Errorf("notice = %+v, want a changed from app to late", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no notice after the missing layer was loaded")
+	}
+
+	l.Close()
+	if n := c.listenerCount(); n != 0 {
+		t.Errorf("%d listeners left after Close", n)
+	}
+}
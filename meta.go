@@ -1,9 +1,13 @@
 package apollo
 
 import (
+	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -15,11 +19,28 @@ const (
 	ENV_PRO = "PRO"
 )
 
-var metaServer = map[string]string{
-	ENV_DEV: "http://127.0.0.1:8080",
-	ENV_FAT: "http://127.0.0.2:8080",
-	ENV_UAT: "http://127.0.0.3:8080",
-	ENV_PRO: "http://127.0.0.4:8080",
+var metaServer = map[string][]string{
+	ENV_DEV: {"http://127.0.0.1:8080"},
+	ENV_FAT: {"http://127.0.0.2:8080"},
+	ENV_UAT: {"http://127.0.0.3:8080"},
+	ENV_PRO: {"http://127.0.0.4:8080"},
+}
+
+// DiscoveryMode config service 的服务发现方式
+type DiscoveryMode string
+
+const (
+	// DiscoveryServices 通过 meta server 的 /services/config 接口（JSON）发现，默认方式
+	DiscoveryServices DiscoveryMode = "services"
+	// DiscoveryEureka 通过 meta server 的 /eureka/apps/APOLLO-CONFIGSERVICE 接口（XML）发现
+	DiscoveryEureka DiscoveryMode = "eureka"
+)
+
+// serviceDTO /services/config 接口返回的服务实例
+type serviceDTO struct {
+	AppName     string `json:"appName"`
+	InstanceId  string `json:"instanceId"`
+	HomepageUrl string `json:"homepageUrl"`
 }
 
 type application struct {
@@ -76,71 +97,136 @@ type instance struct {
 }
 
 type configServer struct {
-	instances []instance
-	lock      sync.RWMutex
-	count     int64
+	mode        DiscoveryMode
+	direct      bool // 直连 config service，不做服务发现
+	metaServers []string
+	metaIdx     int // 最近一次成功的 meta server
+	client      *http.Client
+	retry       RetryPolicy
+	instances   []instance
+	lock        sync.RWMutex
+	count       int64
 }
 
 type configServerOpt interface {
-	getMetaServer(conf *conf) (string, error)
-	getConfigUrl(conf *conf) (string, error)
-	updateServers(conf *conf) error
-	getNotifyUrl(notify *notify, conf *conf) (string, error)
+	getMetaServer(addr string, conf *conf) (string, error)
+	getConfigUrl(addr string, conf *conf, releaseKey, messages string) (string, error)
+	updateServers(ctx context.Context, conf *conf) error
+	getNotifyUrl(addr string, notify *notify, conf *conf) (string, error)
+	getServerAddrs(conf *conf) []string
 }
 
-func (c *configServer) getMetaServer(conf *conf) (string, error) {
-	return fmt.Sprintf("%s/eureka/apps/APOLLO-CONFIGSERVICE", conf.server), nil
+func (c *configServer) getMetaServer(addr string, conf *conf) (string, error) {
+	switch c.mode {
+	case DiscoveryEureka:
+		return fmt.Sprintf("%s/eureka/apps/APOLLO-CONFIGSERVICE", addr), nil
+	case DiscoveryServices, "":
+		return fmt.Sprintf("%s/services/config?appId=%s&ip=%s",
+			addr, url.QueryEscape(conf.appID), conf.clientIP()), nil
+	default:
+		return "", fmt.Errorf("unknown discovery mode: %s", c.mode)
+	}
 }
 
-func (c *configServer) getConfigUrl(conf *conf) (string, error) {
-	addr := conf.server
-	//if conf.env != ENV_DEV {
-	//	ins, err := c.getOneInstance()
-	//	if err != nil {
-	//		return "", err
-	//	}
-	//	addr = ins.HomePage
-	//}
-
-	return fmt.Sprintf("%s/configs/%s/%s/%s?ip=%s",
+func (c *configServer) getConfigUrl(addr string, conf *conf, releaseKey, messages string) (string, error) {
+	u := fmt.Sprintf("%s/configs/%s/%s/%s?%s",
 		addr,
 		conf.appID,
 		conf.cluster,
 		conf.namespace,
-		LocalIP()), nil
+		conf.clientQuery())
+	if releaseKey != "" {
+		u += "&releaseKey=" + url.QueryEscape(releaseKey)
+	}
+	if messages != "" {
+		u += "&messages=" + url.QueryEscape(messages)
+	}
+	return u, nil
 }
 
-func (c *configServer) getNotifyUrl(notify *notify, conf *conf) (string, error) {
-	addr := conf.server
-	//if conf.env != ENV_DEV {
-	//	ins, err := c.getOneInstance()
-	//	if err != nil {
-	//		return "", err
-	//	}
-	//	addr = ins.HomePage
-	//}
-
+func (c *configServer) getNotifyUrl(addr string, notify *notify, conf *conf) (string, error) {
 	n := notify.getNotifyString()
 	return fmt.Sprintf(
-		"%s/notifications/v2?appID=%s&cluster=%s&notifications=%s",
-		addr, conf.appID, conf.cluster, url.QueryEscape(n)), nil
+		"%s/notifications/v2?appId=%s&cluster=%s&notifications=%s&%s",
+		addr, conf.appID, conf.cluster, url.QueryEscape(n), conf.clientQuery()), nil
 }
 
-func (c *configServer) getOneInstance() (instance, error) {
-	size := len(c.instances)
-	if size == 0 {
-		return instance{}, fmt.Errorf("meta server all down %v", *c)
+// clientIP 返回上报给配置中心的客户端 IP
+func (c *conf) clientIP() string {
+	if c.ip != "" {
+		return c.ip
+	}
+	return LocalIP()
+}
+
+// clientQuery 返回配置及通知请求携带的 ip、label、dataCenter 参数
+func (c *conf) clientQuery() string {
+	q := "ip=" + url.QueryEscape(c.clientIP())
+	if c.label != "" {
+		q += "&label=" + url.QueryEscape(c.label)
+	}
+	if c.dataCenter != "" {
+		q += "&dataCenter=" + url.QueryEscape(c.dataCenter)
 	}
+	return q
+}
+
+// isGrayCluster 判断配置中心返回的 cluster 是否为灰度分支：
+// 灰度发布的 cluster 是分支名，不在请求的 cluster、数据中心及 default 之中
+func (c *conf) isGrayCluster(cluster string) bool {
+	return cluster != "" && cluster != c.cluster && cluster != c.dataCenter && cluster != defaultCluster
+}
+
+// getServerAddrs 返回本次请求依次尝试的 config service 地址：起点轮询选取，其余实例用于故障转移；
+// 发现结果为空时退回 meta server
+func (c *configServer) getServerAddrs(conf *conf) []string {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
-	p := int(atomic.LoadInt64(&c.count) % int64(size))
-	ins := c.instances[p]
+	size := len(c.instances)
+	if size == 0 {
+		return []string{c.currentMetaServer()}
+	}
 	//简单的负载均衡
-	atomic.AddInt64(&c.count, 1)
-	return ins, nil
+	p := int((atomic.AddInt64(&c.count, 1) - 1) % int64(size))
+	addrs := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		ins := c.instances[(p+i)%size]
+		if ins.HomePage != "" {
+			addrs = append(addrs, strings.TrimSuffix(ins.HomePage, "/"))
+		}
+	}
+	if len(addrs) == 0 {
+		return []string{c.currentMetaServer()}
+	}
+	return addrs
 }
 
-func (c *configServer) updateServers(conf *conf) error {
+// currentMetaServer 最近一次成功的 meta server，调用方需持有锁
+func (c *configServer) currentMetaServer() string {
+	if len(c.metaServers) == 0 {
+		return ""
+	}
+	return c.metaServers[c.metaIdx]
+}
+
+// setConfigServices 直接使用给定的 config service 地址，之后 updateServers 不再访问 meta server
+func (c *configServer) setConfigServices(addrs []string) {
+	tmp := make([]instance, 0, len(addrs))
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		tmp = append(tmp, instance{HomePage: addr, Status: "UP"})
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.direct = true
+	c.instances = tmp
+}
+
+func (c *configServer) updateServers(ctx context.Context, conf *conf) error {
 
 	defer func() {
 		if err := recover(); err != nil {
@@ -148,31 +234,58 @@ func (c *configServer) updateServers(conf *conf) error {
 		}
 	}()
 
-	if conf.server == "" {
+	c.lock.RLock()
+	direct, metas, start := c.direct, c.metaServers, c.metaIdx
+	c.lock.RUnlock()
+	if direct || len(metas) == 0 {
 		return nil
 	}
-	//fmt.Printf("updating instances with conf %v \n", *conf)
-	serverUrl, err := c.getMetaServer(conf)
+
+	// 从最近一次成功的 meta server 开始依次尝试
+	var err error
+	for i := range metas {
+		idx := (start + i) % len(metas)
+		var tmp []instance
+		tmp, err = c.fetchInstances(ctx, metas[idx], conf)
+		if err == nil {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			c.metaIdx = idx
+			c.instances = tmp
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		logger.Warnf("get config service from meta server %s fail, err: %v", metas[idx], err)
+	}
+	return err
+}
+
+func (c *configServer) fetchInstances(ctx context.Context, addr string, conf *conf) ([]instance, error) {
+	serverUrl, err := c.getMetaServer(addr, conf)
 	if err != nil {
-		return err
-	}
-	//rsp, err := http.Get(serverUrl)
-	//if err != nil {
-	//	return err
-	//}
-	//defer rsp.Body.Close()
-	//data, err := ioutil.ReadAll(rsp.Body)
-	//if err != nil {
-	//	return err
-	//}
-	data, err := httpGet(serverUrl)
+		return nil, err
+	}
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := httpGet(ctx, client, c.retry.withDefaults(), serverUrl)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if c.mode == DiscoveryEureka {
+		return parseEurekaInstances(data)
+	}
+	return parseServiceInstances(data)
+}
+
+func parseEurekaInstances(data []byte) ([]instance, error) {
 	app := application{}
-	err = xml.Unmarshal(data, &app)
+	err := xml.Unmarshal(data, &app)
 	if err != nil {
-		return fmt.Errorf("XML Unmarshal Fail err=%s , GET data=%s", err.Error(), string(data))
+		return nil, fmt.Errorf("XML Unmarshal Fail err=%s , GET data=%s", err.Error(), string(data))
 	}
 	tmp := make([]instance, 0)
 	for _, v := range app.Instance {
@@ -180,12 +293,24 @@ func (c *configServer) updateServers(conf *conf) error {
 			tmp = append(tmp, v)
 		}
 	}
+	return tmp, nil
+}
 
-	//fmt.Printf("up instance %v \n", tmp)
-
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	c.instances = tmp
-
-	return nil
+// /services/config 只返回可用的实例
+func parseServiceInstances(data []byte) ([]instance, error) {
+	var services []serviceDTO
+	err := json.Unmarshal(data, &services)
+	if err != nil {
+		return nil, fmt.Errorf("JSON Unmarshal Fail err=%s , GET data=%s", err.Error(), string(data))
+	}
+	tmp := make([]instance, 0, len(services))
+	for _, v := range services {
+		tmp = append(tmp, instance{
+			ID:       v.InstanceId,
+			App:      v.AppName,
+			HomePage: v.HomepageUrl,
+			Status:   "UP",
+		})
+	}
+	return tmp, nil
 }
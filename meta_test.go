@@ -0,0 +1,108 @@
+package apollo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigServer_RoundRobin(t *testing.T) {
+	s := &configServer{instances: []instance{{HomePage: "http://a/"}, {HomePage: "http://b/"}}}
+	cf := &conf{}
+
+	want := [][]string{{"http://a", "http://b"}, {"http://b", "http://a"}, {"http://a", "http://b"}}
+	for _, w := range want {
+		if got := s.getServerAddrs(cf); strings.Join(got, ",") != strings.Join(w, ",") {
+			t.Fatalf("got %v, want %v", got, w)
+		}
+	}
+
+	empty := &configServer{metaServers: []string{"http://meta"}}
+	if got := empty.getServerAddrs(cf); len(got) != 1 || got[0] != "http://meta" {
+		t.Errorf("got %v without instances, want meta server", got)
+	}
+}
+
+func TestConfigServer_DiscoveryModes(t *testing.T) {
+	f := newFakeApollo(t)
+	f.setInstances("http://10.0.0.1:8080", "http://10.0.0.2:8080")
+
+	for _, mode := range []DiscoveryMode{DiscoveryServices, DiscoveryEureka} {
+		s := &configServer{mode: mode, metaServers: []string{f.URL}}
+		if err := s.updateServers(context.Background(), &conf{appID: "app"}); err != nil {
+			t.Fatalf("%s: updateServers: %v", mode, err)
+		}
+		got := s.getServerAddrs(&conf{})
+		if len(got) != 2 || got[0] != "http://10.0.0.1:8080" || got[1] != "http://10.0.0.2:8080" {
+			t.Errorf("%s: got %v", mode, got)
+		}
+	}
+}
+
+func TestNewClient_ConfigService(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_direct", "application", map[string]string{"name": "direct"})
+
+	t.Setenv(envConfigService, "http://127.0.0.1:1, "+f.URL)
+	c := f.newClient(t, Options{AppID: "app_direct", MetaServer: "http://127.0.0.1:1"})
+
+	if v := c.GetStringValue("name", ""); v != "direct" {
+		t.Errorf("got %q, want direct", v)
+	}
+}
+
+func TestConfigServer_MetaServerFailover(t *testing.T) {
+	f := newFakeApollo(t)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	s := &configServer{metaServers: splitAddrs(broken.URL + ", " + f.URL), retry: RetryPolicy{MaxAttempts: 1}}
+	if err := s.updateServers(context.Background(), &conf{appID: "app"}); err != nil {
+		t.Fatalf("updateServers: %v", err)
+	}
+	if s.metaIdx != 1 {
+		t.Errorf("metaIdx = %d, want the meta server that succeeded", s.metaIdx)
+	}
+	if got := s.getServerAddrs(&conf{}); len(got) != 1 || got[0] != f.URL {
+		t.Errorf("got %v, want %s", got, f.URL)
+	}
+}
+
+func TestConfig_GrayRelease(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_gray", "application", map[string]string{"name": "gray"})
+	f.set("app_gray", "svc.normal", map[string]string{"name": "normal"})
+	f.setGray("app_gray", "application", "canary")
+
+	c := f.newClient(t, Options{AppID: "app_gray",
+		Label: "canary", IP: "10.0.0.1", DataCenter: "idc-a"})
+
+	if !c.IsGrayRelease("application") {
+		t.Error("application should come from gray release")
+	}
+	if c.IsGrayRelease("svc.normal") {
+		t.Error("svc.normal should not be gray before loading")
+	}
+	c.GetNamespace("svc.normal").GetString("name", "")
+	if c.IsGrayRelease("svc.normal") {
+		t.Error("svc.normal should not come from gray release")
+	}
+
+	q := f.query("/configs/app_gray/default/svc.normal")
+	if q.Get("label") != "canary" || q.Get("ip") != "10.0.0.1" || q.Get("dataCenter") != "idc-a" {
+		t.Errorf("config query = %v", q)
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.query("/notifications/v2") == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	q = f.query("/notifications/v2")
+	if q.Get("label") != "canary" || q.Get("ip") != "10.0.0.1" || q.Get("dataCenter") != "idc-a" {
+		t.Errorf("notification query = %v", q)
+	}
+}
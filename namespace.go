@@ -0,0 +1,12 @@
+package apollo
+
+import "strings"
+
+const propertiesSuffix = ".properties"
+
+// normalizeNamespace 统一命名空间名称：去掉 .properties 后缀并转为小写（Apollo 命名空间不区分大小写），
+// 缓存、通知及本地缓存文件都使用统一后的名称
+func normalizeNamespace(namespace string) string {
+	namespace = strings.ToLower(strings.TrimSpace(namespace))
+	return strings.TrimSuffix(namespace, propertiesSuffix)
+}
@@ -0,0 +1,56 @@
+package apollo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeNamespace(t *testing.T) {
+	cases := map[string]string{
+		"application":            "application",
+		"application.properties": "application",
+		"Team.Global.PROPERTIES": "team.global",
+		" db.yaml ":              "db.yaml",
+		"routes.JSON":            "routes.json",
+	}
+	for in, want := range cases {
+		if got := normalizeNamespace(in); got != want {
+			t.Errorf("normalizeNamespace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNotify_NormalizedInt64(t *testing.T) {
+	n := &notify{notifications: make(map[string]int64)}
+	n.put("Foo.properties", -1)
+	n.put("foo", 1<<40)
+	if len(n.notifications) != 1 || n.notifications["foo"] != 1<<40 {
+		t.Fatalf("notifications = %v, want a single normalized 64-bit entry", n.notifications)
+	}
+	if s := n.getNotifyString(); !strings.Contains(s, `"notificationId":1099511627776`) {
+		t.Errorf("notify string lost 64-bit id: %s", s)
+	}
+}
+
+func TestConfig_NormalizedNamespace(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_ns", "application", map[string]string{"name": "app"})
+	f.set("app_ns", "other", map[string]string{"name": "other"})
+
+	c := f.newClient(t, Options{AppID: "app_ns", Namespace: "Application.properties"})
+
+	if v := c.GetStringValue("name", ""); v != "app" {
+		t.Errorf("got %q, want app", v)
+	}
+	for _, ns := range []string{"other", "Other.properties", "OTHER"} {
+		if v := c.GetStringByNameSpace(ns, "name", ""); v != "other" {
+			t.Errorf("GetStringByNameSpace(%q) = %q, want other", ns, v)
+		}
+	}
+	c.lock.RLock()
+	size := len(c.nCache)
+	c.lock.RUnlock()
+	if size != 2 {
+		t.Errorf("cache has %d namespaces, want 2", size)
+	}
+}
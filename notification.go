@@ -6,13 +6,36 @@ import (
 )
 
 type notify struct {
-	notifications map[string]int
+	notifications map[string]int64
+	messages      map[string]*notificationMessages
 	lock          sync.RWMutex
 }
 
 type notification struct {
-	NamespaceName  string `json:"namespaceName,omitempty"`
-	NotificationID int    `json:"notificationId,omitempty"`
+	NamespaceName  string                `json:"namespaceName,omitempty"`
+	NotificationID int64                 `json:"notificationId,omitempty"`
+	Messages       *notificationMessages `json:"messages,omitempty"`
+}
+
+// notificationMessages 对应 ApolloNotificationMessages，记录各 key 的通知水位，
+// 拉取配置时带上，避免读到尚未同步的 config service 副本
+type notificationMessages struct {
+	Details map[string]int64 `json:"details"`
+}
+
+// merge 合并通知消息，同一 key 保留较大的水位
+func (m *notificationMessages) merge(other *notificationMessages) {
+	if other == nil {
+		return
+	}
+	if m.Details == nil {
+		m.Details = make(map[string]int64, len(other.Details))
+	}
+	for k, v := range other.Details {
+		if old, ok := m.Details[k]; !ok || v > old {
+			m.Details[k] = v
+		}
+	}
 }
 
 func (n *notify) getNotifyString() string {
@@ -21,7 +44,8 @@ func (n *notify) getNotifyString() string {
 	var list []*notification
 	for k, v := range n.notifications {
 		list = append(list, &notification{
-			k, v,
+			NamespaceName:  k,
+			NotificationID: v,
 		})
 	}
 	bts, err := json.Marshal(&list)
@@ -31,8 +55,55 @@ func (n *notify) getNotifyString() string {
 	return string(bts)
 }
 
-func (n *notify) put(key string, value int) {
+func (n *notify) put(key string, value int64) {
+	key = normalizeNamespace(key)
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	n.notifications[key] = value
 }
+
+// get 返回命名空间已知的通知 ID，未注册时返回 -1
+func (n *notify) get(key string) int64 {
+	key = normalizeNamespace(key)
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if id, ok := n.notifications[key]; ok {
+		return id
+	}
+	return -1
+}
+
+// putMessages 合并命名空间收到的通知消息
+func (n *notify) putMessages(key string, messages *notificationMessages) {
+	if messages == nil || len(messages.Details) == 0 {
+		return
+	}
+	key = normalizeNamespace(key)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.messages == nil {
+		n.messages = make(map[string]*notificationMessages)
+	}
+	m, ok := n.messages[key]
+	if !ok {
+		m = &notificationMessages{}
+		n.messages[key] = m
+	}
+	m.merge(messages)
+}
+
+// getMessagesString 返回命名空间的通知消息 JSON，没有时返回空串
+func (n *notify) getMessagesString(key string) string {
+	key = normalizeNamespace(key)
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	m, ok := n.messages[key]
+	if !ok {
+		return ""
+	}
+	bts, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(bts)
+}
@@ -0,0 +1,117 @@
+package apollo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// publicLayers 关联 public 命名空间的两层配置：所属 appID 发布的配置和本 appID 的覆盖配置
+type publicLayers struct {
+	public   *configuration
+	override *configuration
+
+	noOverride bool  // 本 appID 没有该命名空间
+	overrideID int64 // 确认没有覆盖配置时本 appID 的通知 ID，通知 ID 变化后才重新拉取
+}
+
+// updatePublicConfig 从所属 appID 拉取 public 命名空间，合并本 appID 的覆盖配置后更新缓存
+func (config *Config) updatePublicConfig(retry RetryPolicy, namespace, owner string) error {
+	config.layerLock.Lock()
+	defer config.layerLock.Unlock()
+
+	layers, ok := config.layers[namespace]
+	if !ok {
+		layers = &publicLayers{public: &configuration{}, override: &configuration{}}
+	}
+
+	pc := config.namespaceConf(owner, namespace)
+	pubData, err := config.fetchConfig(retry, pc, layers.public.ReleaseKey, config.notifiers[owner].getMessagesString(namespace))
+	if err != nil {
+		return err
+	}
+
+	oc := config.namespaceConf(config.conf.appID, namespace)
+	notifyID := config.notify.get(namespace)
+	noOverride := ok && layers.noOverride && layers.overrideID == notifyID
+	var ovData []byte
+	if !noOverride {
+		ovData, err = config.fetchConfig(retry, oc, layers.override.ReleaseKey, config.notify.getMessagesString(namespace))
+		if errors.Is(err, ErrNamespaceNotFound) {
+			// 本 appID 没有关联该命名空间，没有覆盖配置
+			noOverride, err = true, nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if ok && pubData == nil && ovData == nil && noOverride == layers.noOverride {
+		layers.overrideID = notifyID
+		config.touch()
+		return nil
+	}
+
+	public, override := layers.public, layers.override
+	if noOverride {
+		override = &configuration{}
+	}
+	if pubData != nil {
+		public = &configuration{}
+		if err := json.Unmarshal(pubData, public); err != nil {
+			return fmt.Errorf("%w: json parse [%s] fail: %s", ErrInvalidConfig, string(pubData), err.Error())
+		}
+	}
+	if ovData != nil {
+		override = &configuration{}
+		if err := json.Unmarshal(ovData, override); err != nil {
+			return fmt.Errorf("%w: json parse [%s] fail: %s", ErrInvalidConfig, string(ovData), err.Error())
+		}
+	}
+
+	merged := make(map[string]string, len(public.Configuration)+len(override.Configuration))
+	for k, v := range public.Configuration {
+		merged[k] = v
+	}
+	for k, v := range override.Configuration {
+		merged[k] = v
+	}
+	releaseKey := public.ReleaseKey
+	if override.ReleaseKey != "" {
+		releaseKey += "+" + override.ReleaseKey
+	}
+	// 有覆盖配置时以覆盖配置的 cluster 为准，任一层来自灰度发布时保留灰度分支的 cluster
+	cluster := public.Cluster
+	if override.Cluster != "" {
+		cluster = override.Cluster
+	}
+	if config.conf.isGrayCluster(public.Cluster) {
+		cluster = public.Cluster
+	}
+	if config.conf.isGrayCluster(override.Cluster) {
+		cluster = override.Cluster
+	}
+	data, err := json.Marshal(&configuration{
+		AppID:         config.conf.appID,
+		Cluster:       cluster,
+		NameSpace:     namespace,
+		Configuration: merged,
+		ReleaseKey:    releaseKey,
+	})
+	if err != nil {
+		return err
+	}
+	if err := unmarshalData(data, config, namespace); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	config.layers[namespace] = &publicLayers{
+		public:     public,
+		override:   override,
+		noOverride: noOverride,
+		overrideID: notifyID,
+	}
+
+	logger.Infof("Loaded public namespace %s from %s with overrides of %s", namespace, owner, config.conf.appID)
+	config.touch()
+	return saveToFile(data, oc)
+}
@@ -0,0 +1,97 @@
+package apollo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_PublicNamespace(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_own", "application", map[string]string{})
+	f.set("app_owner", "team.public", map[string]string{"a": "public", "b": "public"})
+	f.set("app_own", "team.public", map[string]string{"b": "override"})
+	f.set("app_owner", "team.shared", map[string]string{"a": "shared"})
+
+	c := f.newClient(t, Options{AppID: "app_own",
+		PublicNamespaces: map[string]string{"team.public": "app_owner", "Team.Shared.properties": "app_owner"}})
+
+	ns := c.GetNamespace("team.public")
+	if v := ns.GetString("a", ""); v != "public" {
+		t.Errorf("a = %q, want public", v)
+	}
+	if v := ns.GetString("b", ""); v != "override" {
+		t.Errorf("b = %q, want override", v)
+	}
+	// 本 appID 没有关联的命名空间只使用所属 appID 的配置
+	if v := c.GetNamespace("team.shared").GetString("a", ""); v != "shared" {
+		t.Errorf("shared a = %q, want shared", v)
+	}
+
+	notices := make(chan *Notice, 10)
+	ns.Watch(func(n *Notice) { notices <- n })
+
+	// 所属 appID 发布新配置，通过通知传播到本 appID
+	f.set("app_owner", "team.public", map[string]string{"a": "public2", "b": "public2"})
+	select {
+	case n := <-notices:
+		if n.NewValues["a"] != "public2" || n.NewValues["b"] != "override" {
+			t.Errorf("notice = %+v, want a=public2 b=override", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notice for upstream public change")
+	}
+
+	// 本 appID 修改覆盖配置
+	f.set("app_own", "team.public", map[string]string{"b": "override2"})
+	select {
+	case n := <-notices:
+		if n.NewValues["a"] != "public2" || n.NewValues["b"] != "override2" {
+			t.Errorf("notice = %+v, want a=public2 b=override2", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notice for override change")
+	}
+}
+
+func TestConfig_PublicNamespaceNotModified(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_own", "application", map[string]string{})
+	f.set("app_owner", "team.shared", map[string]string{"a": "shared"})
+
+	c := f.newClient(t, Options{AppID: "app_own", PublicNamespaces: map[string]string{"team.shared": "app_owner"}})
+	ns := c.GetNamespace("team.shared")
+	if v := ns.GetString("a", ""); v != "shared" {
+		t.Fatalf("a = %q, want shared", v)
+	}
+	// 等待启动时的通知处理完
+	time.Sleep(200 * time.Millisecond)
+
+	notices := make(chan *Notice, 10)
+	ns.Watch(func(n *Notice) { notices <- n })
+	overridePath := "/configs/app_own/default/team.shared"
+	before := f.requestCount(overridePath)
+	for i := 0; i < 3; i++ {
+		if err := c.updateConfig("team.shared"); err != nil {
+			t.Fatalf("updateConfig: %v", err)
+		}
+	}
+	select {
+	case n := <-notices:
+		t.Errorf("unexpected notice when nothing changed: %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := f.requestCount(overridePath) - before; n != 0 {
+		t.Errorf("missing override fetched %d more times", n)
+	}
+
+	// 本 appID 新增覆盖配置后通过通知生效
+	f.set("app_own", "team.shared", map[string]string{"a": "override"})
+	select {
+	case n := <-notices:
+		if n.NewValues["a"] != "override" {
+			t.Errorf("notice = %+v, want a=override", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notice for new override")
+	}
+}
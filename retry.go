@@ -0,0 +1,103 @@
+package apollo
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 失败重试策略，服务发现、配置拉取及通知长轮询共用，零值字段使用默认值
+type RetryPolicy struct {
+	// InitialDelay 首次重试前的等待时间，默认 1s
+	InitialDelay time.Duration
+	// MaxDelay 等待时间上限，默认 120s
+	MaxDelay time.Duration
+	// Multiplier 每次失败后等待时间的倍数，默认 2
+	Multiplier float64
+	// Jitter 等待时间随机浮动的比例（0~1），避免所有实例同时重试，默认 0.2；设为 NoJitter 关闭随机浮动
+	Jitter float64
+	// MaxAttempts 服务发现及配置拉取的最多尝试次数，默认 3；通知长轮询不受此限制
+	MaxAttempts int
+}
+
+// NoJitter 用于 RetryPolicy.Jitter，关闭等待时间的随机浮动
+const NoJitter = -1.0
+
+var defaultRetryPolicy = RetryPolicy{
+	InitialDelay: time.Second,
+	MaxDelay:     120 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	MaxAttempts:  3,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaultRetryPolicy.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryPolicy.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultRetryPolicy.Multiplier
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaultRetryPolicy.Jitter
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryPolicy.MaxAttempts
+	}
+	return p
+}
+
+// once 返回只尝试一次的策略，用于调用方同步等待的拉取
+func (p RetryPolicy) once() RetryPolicy {
+	p.MaxAttempts = 1
+	return p
+}
+
+// backoff 第 failures 次（从 0 开始）失败后的等待时间
+func (p RetryPolicy) backoff(failures int) time.Duration {
+	d := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(failures))
+	if d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// do 执行 fn，失败后按策略等待并重试，直到成功、达到 MaxAttempts 或 ctx 取消
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < p.MaxAttempts; attempt++ {
+		err = fn()
+		if err == nil || attempt == p.MaxAttempts-1 {
+			break
+		}
+		d := p.backoff(attempt)
+		logger.Infof("%s, try again after %s, tried: %d", err.Error(), d, attempt+1)
+		if !sleepContext(ctx, d) {
+			break
+		}
+	}
+	return err
+}
+
+// sleepContext 等待 d，ctx 先被取消时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
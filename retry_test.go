@@ -0,0 +1,78 @@
+package apollo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 2, Jitter: 0.2}.withDefaults()
+
+	cases := []struct {
+		failures int
+		base     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{10, 10 * time.Second},
+	}
+	for _, c := range cases {
+		d := p.backoff(c.failures)
+		min := time.Duration(float64(c.base) * 0.8)
+		max := time.Duration(float64(c.base) * 1.2)
+		if d < min || d > max {
+			t.Errorf("backoff(%d) = %s, want within [%s, %s]", c.failures, d, min, max)
+		}
+	}
+}
+
+func TestRetryPolicy_NoJitter(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Second, Jitter: NoJitter}.withDefaults()
+	for i := 0; i < 10; i++ {
+		if d := p.backoff(1); d != 2*time.Second {
+			t.Fatalf("backoff(1) = %s without jitter, want 2s", d)
+		}
+	}
+	if p := (RetryPolicy{}).withDefaults(); p.Jitter != defaultRetryPolicy.Jitter {
+		t.Errorf("zero Jitter = %v, want default %v", p.Jitter, defaultRetryPolicy.Jitter)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Millisecond, MaxAttempts: 3}.withDefaults()
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("do = %v after %d calls, want success after 2", err, calls)
+	}
+
+	calls = 0
+	err = p.do(context.Background(), func() error {
+		calls++
+		return errors.New("fail")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("do = %v after %d calls, want failure after MaxAttempts", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	_ = RetryPolicy{InitialDelay: time.Hour}.withDefaults().do(ctx, func() error {
+		calls++
+		return errors.New("fail")
+	})
+	if calls != 1 {
+		t.Errorf("do made %d calls after cancel, want 1", calls)
+	}
+}
@@ -0,0 +1,290 @@
+package apollo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeApollo 模拟 meta server + config service，供不依赖真实环境的单测使用
+type fakeApollo struct {
+	*httptest.Server
+	lock      sync.Mutex
+	configs   map[string]map[string]string // appID+namespace -> 配置
+	releases  map[string]int               // appID+namespace -> 发布次数，用作 releaseKey
+	instances []string                     // 服务发现返回的 config service 地址，为空时返回自身
+	secret    string                       // 非空时校验 /configs 及 /notifications/v2 请求的签名
+	messages  []string                     // /configs 请求收到的 messages 参数
+	gray      map[string]string            // appID+namespace -> 命中灰度发布的 label
+	queries   map[string]url.Values        // 请求路径 -> 最近一次的请求参数
+	clusters  map[string]string            // appID+namespace -> 仅在该 cluster 存在，未设置时所有 cluster 都存在
+	signed    map[string]int               // 请求路径 -> 通过签名校验的请求数
+	requests  map[string]int               // 请求路径 -> 请求数
+}
+
+func newFakeApollo(t *testing.T) *fakeApollo {
+	f := &fakeApollo{configs: make(map[string]map[string]string), releases: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	useTempCacheDir(t)
+	return f
+}
+
+func newFakeApolloTLS(t *testing.T) *fakeApollo {
+	f := &fakeApollo{configs: make(map[string]map[string]string), releases: make(map[string]int)}
+	f.Server = httptest.NewTLSServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	useTempCacheDir(t)
+	return f
+}
+
+// useTempCacheDir 本地缓存写入测试的临时目录，避免读到 ~/.apollo 中之前运行留下的缓存
+func useTempCacheDir(t *testing.T) {
+	old := cacheDir
+	cacheDir = t.TempDir()
+	t.Cleanup(func() { cacheDir = old })
+}
+
+// newClient 创建连接 f 的客户端，Env、MetaServer 为空时使用 ENV_DEV 和 f.URL，测试结束时关闭
+func (f *fakeApollo) newClient(t *testing.T, opts Options) *Config {
+	t.Helper()
+	if opts.Env == "" {
+		opts.Env = ENV_DEV
+	}
+	if opts.MetaServer == "" {
+		opts.MetaServer = f.URL
+	}
+	c, err := NewClient(opts)
+	if err != nil {
+		t.Fatalf("new client %s: %v", opts.AppID, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (f *fakeApollo) set(appID, namespace string, kv map[string]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.configs[appID+"+"+namespace] = kv
+	f.releases[appID+"+"+namespace]++
+}
+
+// setGray 让 label 匹配的客户端拉取 appID+namespace 时命中灰度分支 gray-branch
+func (f *fakeApollo) setGray(appID, namespace, label string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.gray == nil {
+		f.gray = make(map[string]string)
+	}
+	f.gray[appID+"+"+namespace] = label
+}
+
+// setCluster 让 appID+namespace 只在 cluster 中存在
+func (f *fakeApollo) setCluster(appID, namespace, cluster string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.clusters == nil {
+		f.clusters = make(map[string]string)
+	}
+	f.clusters[appID+"+"+namespace] = cluster
+}
+
+// lookupCluster 同配置中心，依次在 cluster、dataCenter、default 中查找命名空间，返回找到的 cluster
+func (f *fakeApollo) lookupCluster(key, cluster, dataCenter string) (string, bool) {
+	chain := make([]string, 0, 3)
+	if cluster != defaultCluster {
+		chain = append(chain, cluster)
+	}
+	if dataCenter != "" && dataCenter != cluster {
+		chain = append(chain, dataCenter)
+	}
+	chain = append(chain, defaultCluster)
+	only, restricted := f.clusters[key]
+	for _, c := range chain {
+		if !restricted || c == only {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+func (f *fakeApollo) query(path string) url.Values {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.queries[path]
+}
+
+func (f *fakeApollo) requestCount(path string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.requests[path]
+}
+
+func (f *fakeApollo) setInstances(addrs ...string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.instances = addrs
+}
+
+func (f *fakeApollo) getInstances() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.instances) == 0 {
+		return []string{f.URL}
+	}
+	return f.instances
+}
+
+func (f *fakeApollo) authorized(r *http.Request) bool {
+	f.lock.Lock()
+	secret := f.secret
+	f.lock.Unlock()
+	if secret == "" {
+		return true
+	}
+	timestamp := r.Header.Get(headerTimestamp)
+	auth := r.Header.Get(headerAuthorization)
+	if timestamp == "" || !strings.HasPrefix(auth, "Apollo ") ||
+		!strings.HasSuffix(auth, ":"+signature(timestamp, pathWithQuery(r), secret)) {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.signed == nil {
+		f.signed = make(map[string]int)
+	}
+	f.signed[r.URL.Path]++
+	return true
+}
+
+// signedCount 返回路径前缀为 prefix 且通过签名校验的请求数
+func (f *fakeApollo) signedCount(prefix string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	n := 0
+	for path, count := range f.signed {
+		if strings.HasPrefix(path, prefix) {
+			n += count
+		}
+	}
+	return n
+}
+
+// changed 返回发布次数大于客户端已知通知 ID 的命名空间
+func (f *fakeApollo) changed(r *http.Request) []*notification {
+	var known []*notification
+	_ = json.Unmarshal([]byte(r.URL.Query().Get("notifications")), &known)
+	appID := r.URL.Query().Get("appId")
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var changed []*notification
+	for _, n := range known {
+		id := int64(f.releases[appID+"+"+n.NamespaceName])
+		if id > n.NotificationID {
+			key := appID + "+" + r.URL.Query().Get("cluster") + "+" + n.NamespaceName
+			changed = append(changed, &notification{
+				NamespaceName:  n.NamespaceName,
+				NotificationID: id,
+				Messages:       &notificationMessages{Details: map[string]int64{key: id}},
+			})
+		}
+	}
+	return changed
+}
+
+func (f *fakeApollo) serve(w http.ResponseWriter, r *http.Request) {
+	if (strings.HasPrefix(r.URL.Path, "/configs/") || r.URL.Path == "/notifications/v2") && !f.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.lock.Lock()
+	if f.queries == nil {
+		f.queries = make(map[string]url.Values)
+	}
+	f.queries[r.URL.Path] = r.URL.Query()
+	if f.requests == nil {
+		f.requests = make(map[string]int)
+	}
+	f.requests[r.URL.Path]++
+	f.lock.Unlock()
+	switch {
+	case r.URL.Path == "/eureka/apps/APOLLO-CONFIGSERVICE":
+		fmt.Fprint(w, `<application><name>APOLLO-CONFIGSERVICE</name>`)
+		for i, addr := range f.getInstances() {
+			fmt.Fprintf(w, `<instance><instanceId>fake-%d</instanceId><status>UP</status><homePageUrl>%s/</homePageUrl></instance>`, i, addr)
+		}
+		fmt.Fprint(w, `</application>`)
+	case r.URL.Path == "/services/config":
+		var services []serviceDTO
+		for i, addr := range f.getInstances() {
+			services = append(services, serviceDTO{
+				AppName:     "APOLLO-CONFIGSERVICE",
+				InstanceId:  fmt.Sprintf("fake-%d", i),
+				HomepageUrl: addr + "/",
+			})
+		}
+		_ = json.NewEncoder(w).Encode(services)
+	case strings.HasPrefix(r.URL.Path, "/configs/"):
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/configs/"), "/")
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.lock.Lock()
+		kv, ok := f.configs[parts[0]+"+"+parts[2]]
+		releaseKey := fmt.Sprintf("rk-%d", f.releases[parts[0]+"+"+parts[2]])
+		label, gray := f.gray[parts[0]+"+"+parts[2]]
+		cluster, found := f.lookupCluster(parts[0]+"+"+parts[2], parts[1], r.URL.Query().Get("dataCenter"))
+		ok = ok && found
+		f.lock.Unlock()
+		if gray && label == r.URL.Query().Get("label") {
+			cluster = "gray-branch"
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if m := r.URL.Query().Get("messages"); m != "" {
+			f.lock.Lock()
+			f.messages = append(f.messages, m)
+			f.lock.Unlock()
+		}
+		if r.URL.Query().Get("releaseKey") == releaseKey {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(configuration{
+			AppID:         parts[0],
+			Cluster:       cluster,
+			NameSpace:     parts[2],
+			Configuration: kv,
+			ReleaseKey:    releaseKey,
+		})
+	case r.URL.Path == "/notifications/v2":
+		if changed := f.changed(r); len(changed) > 0 {
+			_ = json.NewEncoder(w).Encode(changed)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+		w.WriteHeader(http.StatusNotModified)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// listenerCount 返回当前注册的内部回调数
+func (config *Config) listenerCount() int {
+	config.listenerLock.RLock()
+	defer config.listenerLock.RUnlock()
+	return len(config.listeners)
+}
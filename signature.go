@@ -0,0 +1,39 @@
+package apollo
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	envAccessKeySecret = "APOLLO_ACCESS_KEY_SECRET"
+
+	headerAuthorization = "Authorization"
+	headerTimestamp     = "Timestamp"
+)
+
+// signRequest 按 Apollo 访问密钥规则为请求签名：
+// Authorization: Apollo {appId}:{base64(HmacSHA1(secret, timestamp + "\n" + pathWithQuery))}
+func signRequest(req *http.Request, appID, secret string) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	req.Header.Set(headerAuthorization, "Apollo "+appID+":"+signature(timestamp, pathWithQuery(req), secret))
+	req.Header.Set(headerTimestamp, timestamp)
+}
+
+func signature(timestamp, pathWithQuery, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + pathWithQuery))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func pathWithQuery(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	return path
+}
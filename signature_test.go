@@ -0,0 +1,51 @@
+package apollo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	// 与 Apollo Java 客户端 SignatureTest 相同的用例
+	got := signature("1576478257344", "/configs/100004458/default/application?ip=10.0.0.1", "df23df3f59884980844ff3dada30fa97")
+	if want := "EoKyziXvKqzHgwx+ijDJwgVTDgE="; got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/notifications/v2?appId=app&cluster=default", nil)
+	signRequest(req, "app", "secret")
+
+	timestamp := req.Header.Get(headerTimestamp)
+	if timestamp == "" {
+		t.Fatal("missing Timestamp header")
+	}
+	want := "Apollo app:" + signature(timestamp, "/notifications/v2?appId=app&cluster=default", "secret")
+	if got := req.Header.Get(headerAuthorization); got != want {
+		t.Errorf("Authorization = %s, want %s", got, want)
+	}
+}
+
+func TestNewClient_AccessKey(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_secret", "application", map[string]string{"name": "secret"})
+	f.secret = "s3cr3t"
+
+	c := f.newClient(t, Options{AppID: "app_secret",
+		AccessKeySecrets: map[string]string{"app_secret": "s3cr3t"}})
+	if n := f.signedCount("/configs/app_secret/"); n == 0 {
+		t.Error("no signed /configs request was accepted")
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.signedCount("/notifications/v2") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no signed /notifications/v2 request was accepted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := c.GetStringValue("name", ""); v != "secret" {
+		t.Errorf("got %q, want secret", v)
+	}
+}
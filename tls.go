@@ -0,0 +1,69 @@
+package apollo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSOptions 访问 Apollo 的 TLS 及双向 TLS 设置，作用于服务发现、配置拉取及通知长轮询
+type TLSOptions struct {
+	// CAFile 用于校验服务端证书的 CA 证书（PEM），为空时使用系统根证书
+	CAFile string
+	// CertFile、KeyFile 客户端证书及私钥（PEM），用于双向 TLS
+	CertFile string
+	KeyFile  string
+	// ServerName 覆盖证书校验使用的服务端名称
+	ServerName string
+	// MinVersion 最低 TLS 版本，如 tls.VersionTLS12
+	MinVersion uint16
+}
+
+func (o *TLSOptions) config() (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName: o.ServerName,
+		MinVersion: o.MinVersion,
+	}
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.CAFile)
+		}
+		c.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// applyTLS 返回使用 TLS 设置的 client，只支持 *http.Transport（或未设置 Transport）
+func (o *TLSOptions) applyTLS(client *http.Client) (*http.Client, error) {
+	tlsConfig, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	var tr *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		tr = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		tr = t.Clone()
+	default:
+		return nil, fmt.Errorf("tls options need *http.Transport, got %T", client.Transport)
+	}
+	tr.TLSClientConfig = tlsConfig
+	c := *client
+	c.Transport = tr
+	return &c, nil
+}
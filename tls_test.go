@@ -0,0 +1,132 @@
+package apollo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewClient_TLS(t *testing.T) {
+	f := newFakeApolloTLS(t)
+	f.set("app_tls", "application", map[string]string{"name": "tls"})
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", f.Certificate().Raw)
+
+	c := f.newClient(t, Options{AppID: "app_tls",
+		TLS: &TLSOptions{CAFile: caFile, ServerName: "example.com", MinVersion: tls.VersionTLS12}})
+	if v := c.GetStringValue("name", ""); v != "tls" {
+		t.Errorf("got %q, want tls", v)
+	}
+
+	if _, err := NewClient(Options{AppID: "app_tls", Env: ENV_DEV, MetaServer: f.URL,
+		TLS: &TLSOptions{CAFile: caFile}, Transport: &countingTransport{}}); err == nil {
+		t.Error("expected error for TLS options with a non *http.Transport")
+	}
+}
+
+func TestNewClient_MutualTLS(t *testing.T) {
+	clientCA, clientCert, clientKey := newClientCert(t)
+
+	f := &fakeApollo{configs: make(map[string]map[string]string), releases: make(map[string]int)}
+	f.Server = httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCA)
+	f.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	f.StartTLS()
+	t.Cleanup(f.Close)
+	useTempCacheDir(t)
+	f.set("app_mtls", "application", map[string]string{"name": "mtls"})
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writePEM(t, caFile, "CERTIFICATE", f.Certificate().Raw)
+	writePEM(t, certFile, "CERTIFICATE", clientCert)
+	writePEM(t, keyFile, "EC PRIVATE KEY", clientKey)
+
+	c := f.newClient(t, Options{AppID: "app_mtls",
+		TLS: &TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.com"}})
+	if v := c.GetStringValue("name", ""); v != "mtls" {
+		t.Errorf("got %q with client certificate, want mtls", v)
+	}
+
+	// 不提供客户端证书时服务端拒绝握手，换用空的缓存目录避免读到上面写入的本地缓存
+	useTempCacheDir(t)
+	noCert, err := NewClient(Options{AppID: "app_mtls", Env: ENV_DEV, MetaServer: f.URL,
+		TLS:   &TLSOptions{CAFile: caFile, ServerName: "example.com"},
+		Retry: RetryPolicy{MaxAttempts: 1}})
+	if err == nil {
+		defer noCert.Close()
+		if v := noCert.GetStringValue("name", ""); v != "" {
+			t.Errorf("got %q without client certificate, want handshake failure", v)
+		}
+	}
+}
+
+// newClientCert 生成自签名 CA 及其签发的客户端证书，返回 CA 证书、客户端证书（DER）和私钥（DER）
+func newClientCert(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test client ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, cert, keyDER
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package apollo
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Value 默认命名空间中某个 key 的类型化句柄，只在该 key 变更时解析一次，
+// Get 为无锁的原子读取，适合热点路径
+type Value[T any] struct {
+	key   string
+	def   T
+	parse func(string) (T, error)
+	v      atomic.Value // *T，T 为接口类型时也能存入
+	lock   sync.Mutex   // 串行化写入
+	remove func()
+}
+
+// NewValue 创建自定义解析方式的句柄，key 不存在或解析失败时取 def
+func NewValue[T any](config *Config, key string, def T, parse func(string) (T, error)) *Value[T] {
+	namespace := config.conf.namespace
+	v := &Value[T]{key: key, def: def, parse: parse}
+
+	v.remove = config.addListener(func(notice *Notice) {
+		if notice.Namespace != namespace {
+			return
+		}
+		old, hadOld := notice.OldValues[key]
+		s, ok := notice.NewValues[key]
+		if hadOld == ok && old == s {
+			return
+		}
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		v.set(s, ok)
+	})
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	s, ok := config.GetString(key)
+	v.set(s, ok)
+	return v
+}
+
+// Get 返回当前值
+func (v *Value[T]) Get() T {
+	return *v.v.Load().(*T)
+}
+
+// Close 停止跟随配置变更，之后 Get 返回最后一次的值
+func (v *Value[T]) Close() {
+	v.remove()
+}
+
+// Key 返回句柄对应的 key
+func (v *Value[T]) Key() string {
+	return v.key
+}
+
+func (v *Value[T]) set(s string, ok bool) {
+	if !ok {
+		v.v.Store(&v.def)
+		return
+	}
+	t, err := v.parse(s)
+	if err != nil {
+		logger.Warnf("parse value of key %s fail, use default %v, err: %v", v.key, v.def, err)
+		v.v.Store(&v.def)
+		return
+	}
+	v.v.Store(&t)
+}
+
+// String、Int、Int64、Float64、Bool、Duration 创建常用类型的句柄，key 不存在或解析失败时取 def
+func String(config *Config, key string, def string) *Value[string] {
+	return NewValue(config, key, def, func(s string) (string, error) { return s, nil })
+}
+
+func Int(config *Config, key string, def int) *Value[int] {
+	return NewValue(config, key, def, strconv.Atoi)
+}
+
+func Int64(config *Config, key string, def int64) *Value[int64] {
+	return NewValue(config, key, def, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+}
+
+func Float64(config *Config, key string, def float64) *Value[float64] {
+	return NewValue(config, key, def, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+func Bool(config *Config, key string, def bool) *Value[bool] {
+	return NewValue(config, key, def, strconv.ParseBool)
+}
+
+func Duration(config *Config, key string, def time.Duration) *Value[time.Duration] {
+	return NewValue(config, key, def, time.ParseDuration)
+}
@@ -0,0 +1,82 @@
+package apollo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValue(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_value", "application", map[string]string{"n": "1", "d": "2s", "b": "x"})
+
+	c := f.newClient(t, Options{AppID: "app_value"})
+
+	n := Int(c, "n", 0)
+	d := Duration(c, "d", time.Second)
+	b := Bool(c, "b", true)
+	missing := String(c, "missing", "def")
+	if n.Get() != 1 || d.Get() != 2*time.Second || b.Get() != true || missing.Get() != "def" {
+		t.Fatalf("got n=%d d=%s b=%v missing=%q", n.Get(), d.Get(), b.Get(), missing.Get())
+	}
+
+	f.set("app_value", "application", map[string]string{"n": "2", "d": "2s", "missing": "set"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if n.Get() != 2 || d.Get() != 2*time.Second || b.Get() != true || missing.Get() != "set" {
+		t.Errorf("after change got n=%d d=%s b=%v missing=%q", n.Get(), d.Get(), b.Get(), missing.Get())
+	}
+}
+
+type stringName string
+
+func (n stringName) String() string { return string(n) }
+
+func TestValue_Interface(t *testing.T) {
+	f := newFakeApollo(t)
+	f.set("app_value_iface", "application", map[string]string{"s": "a"})
+
+	c := f.newClient(t, Options{AppID: "app_value_iface"})
+
+	parse := func(s string) (fmt.Stringer, error) {
+		if s == "" {
+			return nil, errors.New("empty")
+		}
+		return stringName(s), nil
+	}
+	// nil 默认值及不同具体类型的值都能存入
+	missing := NewValue[fmt.Stringer](c, "missing", nil, parse)
+	if v := missing.Get(); v != nil {
+		t.Errorf("missing = %v, want nil", v)
+	}
+	s := NewValue[fmt.Stringer](c, "s", time.Second, parse)
+	if v := s.Get(); v == nil || v.String() != "a" {
+		t.Errorf("s = %v, want a", v)
+	}
+
+	f.set("app_value_iface", "application", map[string]string{"s": "", "missing": "set"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if v := s.Get(); v != time.Second {
+		t.Errorf("s = %v after bad value, want default 1s", v)
+	}
+	if v := missing.Get(); v == nil || v.String() != "set" {
+		t.Errorf("missing = %v after change, want set", v)
+	}
+
+	missing.Close()
+	s.Close()
+	f.set("app_value_iface", "application", map[string]string{"s": "b", "missing": "changed"})
+	if err := c.updateConfig("application"); err != nil {
+		t.Fatalf("updateConfig: %v", err)
+	}
+	if v := missing.Get(); v.String() != "set" {
+		t.Errorf("missing = %v after Close, want set", v)
+	}
+	if n := c.listenerCount(); n != 0 {
+		t.Errorf("%d listeners left after Close", n)
+	}
+}